@@ -17,6 +17,7 @@ import (
 const (
 	maxRecordableLatencyNS = 300000000000
 	sigFigs                = 5
+	reportSigFigs          = 3
 	defaultBurst           = 1000
 )
 
@@ -39,6 +40,31 @@ type Requester interface {
 	Teardown() error
 }
 
+// Reporter may optionally be implemented by a Requester to contribute
+// additional results, such as latencies of individual request phases, counts
+// of response categories or the client settings used, to the Summary. Report
+// is called once after the benchmark completes and before Teardown.
+type Reporter interface {
+	// Report returns the additional results collected by the Requester.
+	Report() *Report
+}
+
+// Labeler may optionally be implemented by a Requester which issues different
+// kinds of requests, e.g. a weighted mix of operations. Label is called after
+// each request and returns the label of the request just issued. Request
+// latencies and totals are then also recorded per label in Summary.Labels,
+// unless the label is empty.
+type Labeler interface {
+	// Label returns the label of the last request.
+	Label() string
+}
+
+// NewHistogram returns a Histogram suitable for recording latencies in
+// nanoseconds for inclusion in a Report.
+func NewHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, maxRecordableLatencyNS, reportSigFigs)
+}
+
 // Benchmark performs a system benchmark by attempting to issue requests at a
 // specified rate and capturing the latency distribution. The request rate is
 // divided across the number of configured connections.
@@ -59,6 +85,15 @@ type Benchmark struct {
 func NewBenchmark(factory RequesterFactory, requestRate, connections uint64,
 	duration time.Duration, burst uint64) *Benchmark {
 
+	return newBenchmark(factory, requestRate, connections, 0, duration, burst)
+}
+
+// newBenchmark creates a Benchmark whose connections are numbered starting at
+// first, so the Requesters of Benchmarks run by different Workers don't
+// collide.
+func newBenchmark(factory RequesterFactory, requestRate, connections, first uint64,
+	duration time.Duration, burst uint64) *Benchmark {
+
 	if connections == 0 {
 		connections = 1
 	}
@@ -66,7 +101,7 @@ func NewBenchmark(factory RequesterFactory, requestRate, connections uint64,
 	benchmarks := make([]*connectionBenchmark, connections)
 	for i := uint64(0); i < connections; i++ {
 		benchmarks[i] = newConnectionBenchmark(
-			factory.GetRequester(i), requestRate/connections, duration, burst)
+			factory.GetRequester(first+i), requestRate/connections, duration, burst)
 	}
 
 	return &Benchmark{connections: connections, benchmarks: benchmarks}
@@ -75,6 +110,12 @@ func NewBenchmark(factory RequesterFactory, requestRate, connections uint64,
 // Run the benchmark and return a summary of the results. An error is returned
 // if something went wrong along the way.
 func (b *Benchmark) Run() (*Summary, error) {
+	return b.runAt(time.Time{})
+}
+
+// runAt runs the benchmark like Run, but waits until the given time after
+// setting up before starting, unless it's zero or has passed.
+func (b *Benchmark) runAt(startAt time.Time) (*Summary, error) {
 	var (
 		start   = make(chan struct{})
 		results = make(chan *result, b.connections)
@@ -95,6 +136,9 @@ func (b *Benchmark) Run() (*Summary, error) {
 	}
 
 	// Start benchmark
+	if wait := time.Until(startAt); wait > 0 {
+		time.Sleep(wait)
+	}
 	close(start)
 
 	// Wait for completion
@@ -146,6 +190,8 @@ type connectionBenchmark struct {
 	errorTotal                  uint64
 	elapsed                     time.Duration
 	burst                       int
+	labeler                     Labeler
+	labels                      map[string]*LabelSummary
 }
 
 // newConnectionBenchmark creates a connectionBenchmark which runs a system
@@ -164,8 +210,10 @@ func newConnectionBenchmark(requester Requester, requestRate uint64, duration ti
 		burst = uint64(math.Max(1, math.Min(float64(requestRate)*0.1, float64(defaultBurst))))
 	}
 
+	labeler, _ := requester.(Labeler)
 	return &connectionBenchmark{
 		requester:                   requester,
+		labeler:                     labeler,
 		requestRate:                 requestRate,
 		duration:                    duration,
 		expectedInterval:            interval,
@@ -185,6 +233,7 @@ func (c *connectionBenchmark) setup() error {
 	c.uncorrectedErrorHistogram.Reset()
 	c.successTotal = 0
 	c.errorTotal = 0
+	c.labels = make(map[string]*LabelSummary)
 	return c.requester.Setup()
 }
 
@@ -245,6 +294,11 @@ func (c *connectionBenchmark) runRateLimited() (time.Duration, error) {
 				}
 				c.successTotal++
 			}
+			if c.labeler != nil {
+				if err := c.recordLabel(latency, interval, err != nil); err != nil {
+					return 0, err
+				}
+			}
 		}
 	}
 }
@@ -276,12 +330,46 @@ func (c *connectionBenchmark) runFullThrottle() (time.Duration, error) {
 			}
 			c.successTotal++
 		}
+		if c.labeler != nil {
+			if err := c.recordLabel(latency, 0, err != nil); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// recordLabel records the latency of the last request in the histograms of
+// its label. The latency is corrected for coordinated omission using the
+// expected interval between requests, unless the interval is zero.
+func (c *connectionBenchmark) recordLabel(latency, interval int64, failed bool) error {
+	label := c.labeler.Label()
+	if label == "" {
+		return nil
 	}
+	summary, ok := c.labels[label]
+	if !ok {
+		summary = newLabelSummary()
+		c.labels[label] = summary
+	}
+	histogram, uncorrected := summary.SuccessHistogram, summary.UncorrectedSuccessHistogram
+	if failed {
+		histogram, uncorrected = summary.ErrorHistogram, summary.UncorrectedErrorHistogram
+		summary.ErrorTotal++
+	} else {
+		summary.SuccessTotal++
+	}
+	if interval == 0 {
+		return histogram.RecordValue(latency)
+	}
+	if err := histogram.RecordCorrectedValue(latency, interval); err != nil {
+		return err
+	}
+	return uncorrected.RecordValue(latency)
 }
 
 // summarize returns a Summary of the last benchmark run.
 func (c *connectionBenchmark) summarize() *Summary {
-	return &Summary{
+	summary := &Summary{
 		SuccessTotal:                c.successTotal,
 		ErrorTotal:                  c.errorTotal,
 		TimeElapsed:                 c.elapsed,
@@ -291,5 +379,16 @@ func (c *connectionBenchmark) summarize() *Summary {
 		UncorrectedErrorHistogram:   hdrhistogram.Import(c.uncorrectedErrorHistogram.Export()),
 		Throughput:                  float64(c.successTotal+c.errorTotal) / c.elapsed.Seconds(),
 		RequestRate:                 c.requestRate,
+		Histograms:                  make(map[string]*hdrhistogram.Histogram),
+		Counters:                    make(map[string]uint64),
+		Metadata:                    make(map[string]string),
+		Labels:                      make(map[string]*LabelSummary),
+	}
+	for label, labelSummary := range c.labels {
+		summary.Labels[label] = labelSummary.copy()
+	}
+	if reporter, ok := c.requester.(Reporter); ok {
+		summary.addReport(reporter.Report())
 	}
+	return summary
 }
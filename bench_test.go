@@ -0,0 +1,140 @@
+package bench_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ssd532/bench/v2"
+	"github.com/ssd532/bench/v2/requester"
+)
+
+// checkQuantile reports an error if the value of the histogram at the
+// percentile isn't in [min, max].
+func checkQuantile(t *testing.T, name string, histogram *hdrhistogram.Histogram, percentile float64, min, max time.Duration) {
+	t.Helper()
+	got := time.Duration(histogram.ValueAtQuantile(percentile))
+	if got < min || got > max {
+		t.Errorf("got %s p%v of %s, want [%s, %s]", name, percentile, got, min, max)
+	}
+}
+
+// sleepGranularity returns the 99th percentile of how much a short
+// time.Sleep overshoots on this machine, which ranges from microseconds to
+// over a millisecond depending on its timers. The simulated requester sleeps
+// for its simulated latencies, so the measured latencies exceed the
+// simulated ones by up to as much.
+func sleepGranularity() time.Duration {
+	const d = 50 * time.Microsecond
+	histogram := bench.NewHistogram()
+	for i := 0; i < 100; i++ {
+		start := time.Now()
+		time.Sleep(d)
+		histogram.RecordValue((time.Since(start) - d).Nanoseconds())
+	}
+	return time.Duration(histogram.ValueAtQuantile(99))
+}
+
+func TestBenchmarkConstantLatency(t *testing.T) {
+	granularity := sleepGranularity()
+	factory := &requester.SimulatedRequesterFactory{
+		Latency:   requester.ConstantLatency(2 * time.Millisecond),
+		ErrorRate: 0.2,
+		Seed:      1,
+	}
+	summary, err := bench.NewBenchmark(factory, 400, 2, 2*time.Second, 1).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests complete before the next one is due, so correcting the
+	// latencies doesn't change them, and both match the simulated ones up
+	// to the sleep granularity.
+	simulated := summary.Histograms["simulated"]
+	for _, percentile := range []float64{50, 90} {
+		want := time.Duration(simulated.ValueAtQuantile(percentile))
+		checkQuantile(t, "corrected", summary.SuccessHistogram, percentile, want, want+granularity+time.Millisecond)
+		checkQuantile(t, "uncorrected", summary.UncorrectedSuccessHistogram, percentile, want, want+granularity+time.Millisecond)
+	}
+
+	total := summary.SuccessTotal + summary.ErrorTotal
+	if got := float64(summary.ErrorTotal) / float64(total); math.Abs(got-factory.ErrorRate) > 0.04 {
+		t.Errorf("got error rate %.3f, want %.3f", got, factory.ErrorRate)
+	}
+	if got := summary.Counters["simulated.errors"]; got != summary.ErrorTotal {
+		t.Errorf("got %d simulated errors, want %d", got, summary.ErrorTotal)
+	}
+	if math.Abs(summary.Throughput-400)/400 > 0.1 {
+		t.Errorf("got throughput %.0f/s, want about 400/s", summary.Throughput)
+	}
+}
+
+func TestBenchmarkStalls(t *testing.T) {
+	const service = 100 * time.Microsecond
+	granularity := sleepGranularity()
+	factory := &requester.SimulatedRequesterFactory{
+		Latency:       requester.ConstantLatency(service),
+		StallInterval: 200 * time.Millisecond,
+		StallDuration: 50 * time.Millisecond,
+	}
+	summary, err := bench.NewBenchmark(factory, 1000, 1, 2*time.Second, 1).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A quarter of the requests are due during a stall and wait until it
+	// ends, so the latency of requests due at a constant rate exceeds
+	// 50ms - x·200ms with probability x for x up to 25%. The requests
+	// actually issued during a stall are rare, so the simulated and
+	// uncorrected latencies hide the stalls, except for their maximum. The
+	// first request of a stall is issued up to a request interval plus the
+	// sleep granularity after the stall begins, and the histograms round
+	// values up to three significant digits.
+	simulated := summary.Histograms["simulated"]
+	late := time.Millisecond + granularity
+	checkQuantile(t, "corrected", summary.SuccessHistogram, 85, 20*time.Millisecond-late, 20*time.Millisecond+5*time.Millisecond)
+	checkQuantile(t, "corrected", summary.SuccessHistogram, 95, 40*time.Millisecond-late, 40*time.Millisecond+5*time.Millisecond)
+	checkQuantile(t, "simulated", simulated, 100, factory.StallDuration-late, (factory.StallDuration+service)*1001/1000)
+	// The maximum is a single request, so allow for a stray scheduling
+	// delay on top of the sleep granularity.
+	max := time.Duration(simulated.Max())
+	checkQuantile(t, "corrected", summary.SuccessHistogram, 100, max, max+granularity+10*time.Millisecond)
+	for _, percentile := range []float64{50, 95} {
+		want := time.Duration(simulated.ValueAtQuantile(percentile))
+		checkQuantile(t, "uncorrected", summary.UncorrectedSuccessHistogram, percentile, want, want+granularity+time.Millisecond)
+	}
+	if p95 := time.Duration(simulated.ValueAtQuantile(95)); p95 > time.Millisecond {
+		t.Errorf("got simulated p95 of %s, want less than 1ms", p95)
+	}
+}
+
+func TestBenchmarkArrivalRate(t *testing.T) {
+	const (
+		service = time.Millisecond
+		rho     = 0.5
+	)
+	granularity := sleepGranularity()
+	factory := &requester.SimulatedRequesterFactory{
+		Latency:     requester.ConstantLatency(service),
+		ArrivalRate: rho / service.Seconds(),
+		Seed:        1,
+	}
+	// Requests are due every 10ms on each connection, far longer than the
+	// simulated latencies, so the corrected latencies are the simulated
+	// ones up to the sleep granularity.
+	summary, err := bench.NewBenchmark(factory, 400, 4, 2*time.Second, 1).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mean latency of an M/D/1 queue is D + ρD/(2(1-ρ)).
+	want := float64(service) + rho*float64(service)/(2*(1-rho))
+	if got := summary.Histograms["simulated"].Mean(); math.Abs(got-want) > 0.1*want {
+		t.Errorf("got simulated mean %s, want %s ± 10%%", time.Duration(got), time.Duration(want))
+	}
+	got := summary.SuccessHistogram.Mean()
+	if min, max := 0.9*want, 1.1*want+float64(granularity); got < min || got > max {
+		t.Errorf("got corrected mean %s, want [%s, %s]", time.Duration(got), time.Duration(min), time.Duration(max))
+	}
+}
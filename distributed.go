@@ -0,0 +1,236 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStartDelay is the time Workers are given to set up before a
+// distributed benchmark starts.
+const defaultStartDelay = 5 * time.Second
+
+// WorkerJob describes the share of a distributed benchmark run by a Worker.
+type WorkerJob struct {
+	// Requester and Config are the name and configuration of the requester
+	// passed to Worker.NewFactory.
+	Requester string
+	Config    map[string]interface{}
+
+	// RequestRate, Connections, Duration and Burst are the arguments of
+	// NewBenchmark for the Worker's share.
+	RequestRate uint64
+	Connections uint64
+	Duration    time.Duration
+	Burst       uint64
+
+	// FirstConnection is the number of the Worker's first connection, so
+	// connections are numbered uniquely across Workers.
+	FirstConnection uint64
+
+	// Start is the time the benchmark starts, after all Workers have set
+	// up.
+	Start time.Time
+}
+
+// Worker runs shares of a distributed benchmark on behalf of a Coordinator. It
+// implements http.Handler, serving a single endpoint, POST /run, which takes a
+// WorkerJob, runs it and responds with the resulting Summary once the share
+// completes. A Worker runs one job at a time.
+type Worker struct {
+	// NewFactory creates the RequesterFactory of a job from its requester's
+	// name and configuration, e.g. requester.NewFactory.
+	NewFactory func(name string, cfg map[string]interface{}) (RequesterFactory, error)
+
+	mu      sync.Mutex
+	running bool
+}
+
+// ServeHTTP handles requests of a Coordinator.
+func (w *Worker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/run" {
+		http.NotFound(rw, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var job WorkerJob
+	if err := json.NewDecoder(r.Body).Decode(&job); err != nil {
+		http.Error(rw, "bench: invalid job: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	factory, err := w.NewFactory(job.Requester, job.Config)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !w.acquire() {
+		http.Error(rw, "bench: worker is busy", http.StatusConflict)
+		return
+	}
+	defer w.release()
+	benchmark := newBenchmark(factory, job.RequestRate, job.Connections, job.FirstConnection, job.Duration, job.Burst)
+	summary, err := benchmark.runAt(job.Start)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(summary); err != nil {
+		// The status has been sent, so the Coordinator can only notice the
+		// truncated summary.
+		log.Printf("bench: failed to send summary: %v", err)
+	}
+}
+
+// acquire marks the Worker as running a job and indicates if it wasn't
+// already.
+func (w *Worker) acquire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return false
+	}
+	w.running = true
+	return true
+}
+
+// release marks the Worker as idle.
+func (w *Worker) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running = false
+}
+
+// Coordinator runs a benchmark distributed across Workers in other
+// processes, e.g. to generate more load than a single machine can. The
+// request rate and connections are divided across the Workers, which start at
+// the same time and whose Summaries are merged like those of the connections
+// of a Benchmark. Workers' clocks should be synchronized, e.g. using NTP.
+type Coordinator struct {
+	// Workers are the base URLs of the Workers, e.g. "http://host:8080".
+	Workers []string
+
+	// Client is used to send jobs to Workers. Defaults to
+	// http.DefaultClient. It shouldn't time out before the benchmark
+	// completes.
+	Client *http.Client
+
+	// StartDelay is the time Workers are given to set up before the
+	// benchmark starts. Workers taking longer start late. Defaults to 5
+	// seconds.
+	StartDelay time.Duration
+}
+
+// Run runs a distributed benchmark of the requester with the given name and
+// configuration and returns a summary of the results of all Workers. The
+// arguments are those of NewBenchmark for the whole benchmark. The connections
+// are divided as evenly as possible across the Workers, every Worker running
+// at least one, and each Worker's request rate is its share of the
+// connections' rates. A non-zero request rate must be at least the number of
+// connections. An error is returned if a Worker fails.
+func (c *Coordinator) Run(requester string, config map[string]interface{}, requestRate, connections uint64,
+	duration time.Duration, burst uint64) (*Summary, error) {
+
+	workers := uint64(len(c.Workers))
+	if workers == 0 {
+		return nil, errors.New("bench: no workers configured")
+	}
+	if connections < workers {
+		connections = workers
+	}
+	if requestRate > 0 && requestRate < connections {
+		return nil, fmt.Errorf("bench: request rate %d is lower than the %d connections", requestRate, connections)
+	}
+	startDelay := c.StartDelay
+	if startDelay <= 0 {
+		startDelay = defaultStartDelay
+	}
+	start := time.Now().Add(startDelay)
+
+	var (
+		summaries = make([]*Summary, workers)
+		errs      = make([]error, workers)
+		wg        sync.WaitGroup
+		first     uint64
+	)
+	for i, worker := range c.Workers {
+		conns := share(connections, workers, uint64(i))
+		job := &WorkerJob{
+			Requester:       requester,
+			Config:          config,
+			RequestRate:     requestRate * conns / connections,
+			Connections:     conns,
+			Duration:        duration,
+			Burst:           burst,
+			FirstConnection: first,
+			Start:           start,
+		}
+		first += job.Connections
+		wg.Add(1)
+		go func(i int, worker string) {
+			defer wg.Done()
+			summaries[i], errs[i] = c.run(worker, job)
+		}(i, worker)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("bench: worker %s: %v", c.Workers[i], err)
+		}
+	}
+	summary := summaries[0]
+	for _, s := range summaries[1:] {
+		summary.merge(s)
+		summary.Connections += s.Connections
+	}
+	return summary, nil
+}
+
+// run sends the job to the Worker and returns its Summary.
+func (c *Coordinator) run(worker string, job *WorkerJob) (*Summary, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(strings.TrimSuffix(worker, "/")+"/run", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	var summary Summary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// share returns the share of worker i of n of the total, distributing the
+// remainder across the first Workers.
+func share(total, n, i uint64) uint64 {
+	s := total / n
+	if i < total%n {
+		s++
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package bench_test
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ssd532/bench/v2"
+	"github.com/ssd532/bench/v2/requester"
+)
+
+func TestSummaryJSON(t *testing.T) {
+	factory := &requester.MixRequesterFactory{Members: []requester.MixMember{
+		{Name: "fast", Factory: &requester.SimulatedRequesterFactory{ErrorRate: 0.1}},
+		{Name: "slow", Factory: &requester.SimulatedRequesterFactory{Latency: requester.ConstantLatency(time.Millisecond)}},
+	}}
+	summary, err := bench.NewBenchmark(factory, 1000, 2, 200*time.Millisecond, 0).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded bench.Summary
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Connections != summary.Connections || decoded.RequestRate != summary.RequestRate ||
+		decoded.SuccessTotal != summary.SuccessTotal || decoded.ErrorTotal != summary.ErrorTotal ||
+		decoded.TimeElapsed != summary.TimeElapsed || decoded.Throughput != summary.Throughput {
+		t.Errorf("got %v, want %v", &decoded, summary)
+	}
+	checkHistogram(t, "SuccessHistogram", decoded.SuccessHistogram, summary.SuccessHistogram)
+	checkHistogram(t, "UncorrectedSuccessHistogram", decoded.UncorrectedSuccessHistogram, summary.UncorrectedSuccessHistogram)
+	checkHistogram(t, "ErrorHistogram", decoded.ErrorHistogram, summary.ErrorHistogram)
+	checkHistogram(t, "UncorrectedErrorHistogram", decoded.UncorrectedErrorHistogram, summary.UncorrectedErrorHistogram)
+	if len(decoded.Histograms) != len(summary.Histograms) {
+		t.Errorf("got %d histograms, want %d", len(decoded.Histograms), len(summary.Histograms))
+	}
+	for name, histogram := range summary.Histograms {
+		checkHistogram(t, name, decoded.Histograms[name], histogram)
+	}
+	if !reflect.DeepEqual(decoded.Counters, summary.Counters) {
+		t.Errorf("got Counters %v, want %v", decoded.Counters, summary.Counters)
+	}
+	if !reflect.DeepEqual(decoded.Metadata, summary.Metadata) {
+		t.Errorf("got Metadata %v, want %v", decoded.Metadata, summary.Metadata)
+	}
+	if len(decoded.Labels) != 2 || len(summary.Labels) != 2 {
+		t.Fatalf("got %d labels, want %d", len(decoded.Labels), len(summary.Labels))
+	}
+	for label, l := range summary.Labels {
+		d := decoded.Labels[label]
+		if d == nil || d.SuccessTotal != l.SuccessTotal || d.ErrorTotal != l.ErrorTotal {
+			t.Errorf("got label %q %+v, want %+v", label, d, l)
+			continue
+		}
+		checkHistogram(t, label+" SuccessHistogram", d.SuccessHistogram, l.SuccessHistogram)
+		checkHistogram(t, label+" ErrorHistogram", d.ErrorHistogram, l.ErrorHistogram)
+	}
+}
+
+// checkHistogram reports an error if the histograms differ.
+func checkHistogram(t *testing.T, name string, got, want *hdrhistogram.Histogram) {
+	t.Helper()
+	if got == nil || !got.Equals(want) {
+		t.Errorf("%s differs after decoding", name)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	workers := make([]string, 2)
+	for i := range workers {
+		server := httptest.NewServer(&bench.Worker{NewFactory: requester.NewFactory})
+		defer server.Close()
+		workers[i] = server.URL
+	}
+	coordinator := &bench.Coordinator{Workers: workers, StartDelay: 200 * time.Millisecond}
+
+	summary, err := coordinator.Run("simulated", map[string]interface{}{"ErrorRate": 0.1}, 1500, 3, time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Connections != 3 {
+		t.Errorf("got %d connections, want 3", summary.Connections)
+	}
+	if summary.RequestRate != 1500 {
+		t.Errorf("got request rate %d, want 1500", summary.RequestRate)
+	}
+	if math.Abs(summary.Throughput-1500)/1500 > 0.1 {
+		t.Errorf("got throughput %.0f/s, want about 1500/s", summary.Throughput)
+	}
+	total := summary.SuccessTotal + summary.ErrorTotal
+	if got := summary.SuccessHistogram.TotalCount() + summary.ErrorHistogram.TotalCount(); got < int64(total) {
+		t.Errorf("got %d recorded latencies, want at least %d", got, total)
+	}
+	if got := summary.Counters["simulated.errors"]; got != summary.ErrorTotal {
+		t.Errorf("got %d simulated errors, want %d", got, summary.ErrorTotal)
+	}
+}
+
+func TestCoordinatorRateLowerThanConnections(t *testing.T) {
+	server := httptest.NewServer(&bench.Worker{NewFactory: requester.NewFactory})
+	defer server.Close()
+	coordinator := &bench.Coordinator{Workers: []string{server.URL}}
+	if _, err := coordinator.Run("noop", nil, 2, 3, time.Second, 0); err == nil {
+		t.Fatal("Run succeeded with a request rate lower than the connections")
+	}
+}
+
+func TestCoordinatorWorkerError(t *testing.T) {
+	server := httptest.NewServer(&bench.Worker{NewFactory: requester.NewFactory})
+	defer server.Close()
+	coordinator := &bench.Coordinator{Workers: []string{server.URL}, StartDelay: 10 * time.Millisecond}
+	if _, err := coordinator.Run("unknown", nil, 0, 1, time.Second, 0); err == nil {
+		t.Fatal("Run succeeded with an unknown requester")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+	"github.com/ssd532/bench/v2/requester"
+)
+
+// Start workers, e.g. on localhost:
+//
+//	go run ./examples/distributed -listen :8081
+//	go run ./examples/distributed -listen :8082
+//
+// and run the benchmark across them:
+//
+//	go run ./examples/distributed -workers http://localhost:8081,http://localhost:8082
+func main() {
+	var (
+		listen    = flag.String("listen", "", "run a worker listening on this address")
+		workers   = flag.String("workers", "", "comma-separated worker URLs to coordinate")
+		name      = flag.String("requester", "nats", "registered requester name")
+		config    = flag.String("config", `{"URL": "nats://localhost:4222", "PayloadSize": 1000, "Subject": "benchmark"}`, "requester configuration as JSON")
+		rate      = flag.Uint64("rate", 100000, "total requests per second")
+		conns     = flag.Uint64("connections", 2, "total connections")
+		duration  = flag.Duration("duration", 30*time.Second, "benchmark duration")
+		histogram = flag.String("out", "distributed.txt", "latency distribution file")
+	)
+	flag.Parse()
+
+	if *listen != "" {
+		worker := &bench.Worker{NewFactory: requester.NewFactory}
+		panic(http.ListenAndServe(*listen, worker))
+	}
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(*config), &cfg); err != nil {
+		panic(err)
+	}
+	coordinator := &bench.Coordinator{Workers: strings.Split(*workers, ",")}
+	summary, err := coordinator.Run(*name, cfg, *rate, *conns, *duration, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(summary)
+	summary.GenerateLatencyDistribution(nil, *histogram)
+}
@@ -4,18 +4,29 @@ go 1.16
 
 require (
 	github.com/HdrHistogram/hdrhistogram-go v1.1.0
-	github.com/Shopify/sarama v1.29.1
+	github.com/Shopify/sarama v1.38.1
+	github.com/Shopify/toxiproxy v2.1.4+incompatible // indirect
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/garyburd/redigo v1.6.2
 	github.com/gocql/gocql v0.0.0-20210707082121-9a3953d1826d
 	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/gorilla/websocket v1.4.2
 	github.com/liftbridge-io/go-liftbridge/v2 v2.1.0
-	github.com/nats-io/nats-server/v2 v2.3.3-0.20210719165541-e8fea67b1a38 // indirect
-	github.com/nats-io/nats-streaming-server v0.22.0 // indirect
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/mitchellh/go-homedir v1.1.0 // indirect
+	github.com/nats-io/nats-server/v2 v2.3.3-0.20210719165541-e8fea67b1a38
+	github.com/nats-io/nats-streaming-server v0.22.0
 	github.com/nats-io/nats.go v1.11.1-0.20210623165838-4b75fc59ae30
 	github.com/nats-io/stan.go v0.9.0
 	github.com/nsqio/go-nsq v1.0.8
-	github.com/rabbitmq/rabbitmq-stream-go-client v0.0.0-20210720070408-b04b01a752c3
+	github.com/rabbitmq/rabbitmq-stream-go-client v1.0.1
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/spf13/viper v1.7.0 // indirect
 	github.com/streadway/amqp v1.0.0
+	github.com/xdg/scram v1.0.3 // indirect
+	github.com/xdg/stringprep v1.0.3 // indirect
+	golang.org/x/net v0.5.0
 	golang.org/x/time v0.0.0-20210611083556-38a9dc6acbc6
-	google.golang.org/protobuf v1.27.1 // indirect
+	google.golang.org/grpc v1.32.0
+	google.golang.org/protobuf v1.28.1
 )
@@ -2,6 +2,7 @@ package requester
 
 import (
 	"errors"
+	"fmt"
 	"github.com/ssd532/bench/v2"
 	"strconv"
 	"time"
@@ -16,15 +17,75 @@ type AMQPRequesterFactory struct {
 	PayloadSize int
 	Queue       string
 	Exchange    string
+
+	// Confirm enables publisher confirms. Every request waits for the
+	// broker's confirm of the message before consuming it, and negative
+	// acknowledgements are errors. With PublishOnly, the latency is the time
+	// until the confirm.
+	Confirm bool
+
+	// PublishOnly doesn't consume published messages.
+	PublishOnly bool
+
+	// Persistent publishes messages with the persistent delivery mode.
+	Persistent bool
+
+	// Durable declares a durable exchange and queue, which are deleted in
+	// Teardown. QueueType is "classic" or "quorum", which implies Durable.
+	// Defaults to a transient, exclusive classic queue.
+	Durable   bool
+	QueueType string
+
+	// ExchangeType is one of "fanout", "direct", "topic" or "headers".
+	// Defaults to "fanout".
+	ExchangeType string
+
+	// RoutingKey is the routing key of published messages and BindingKey the
+	// key binding the queue to the exchange. Both default to the queue name.
+	// BindingArgs and Headers are the binding arguments and message headers,
+	// e.g. for headers exchanges.
+	RoutingKey  string
+	BindingKey  string
+	BindingArgs amqp.Table
+	Headers     amqp.Table
+
+	// ManualAck acknowledges every consumed message rather than using
+	// automatic acknowledgement. Prefetch, if greater than zero, limits the
+	// number of unacknowledged messages delivered.
+	ManualAck bool
+	Prefetch  int
+
+	// Mandatory publishes messages as mandatory. Messages returned by the
+	// broker because they can't be routed are errors. With PublishOnly and
+	// without Confirm, requests don't wait for returns, so a returned
+	// message fails a later request.
+	Mandatory bool
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (r *AMQPRequesterFactory) GetRequester(num uint64) bench.Requester {
+	queueName := r.Queue + "-" + strconv.FormatUint(num, 10)
+	exchangeType := r.ExchangeType
+	if exchangeType == "" {
+		exchangeType = amqp.ExchangeFanout
+	}
+	routingKey := r.RoutingKey
+	if routingKey == "" {
+		routingKey = queueName
+	}
+	bindingKey := r.BindingKey
+	if bindingKey == "" {
+		bindingKey = queueName
+	}
 	return &amqpRequester{
 		url:          r.URL,
 		payloadSize:  r.PayloadSize,
-		queueName:    r.Queue + "-" + strconv.FormatUint(num, 10),
+		queueName:    queueName,
 		exchangeName: r.Exchange + "-" + strconv.FormatUint(num, 10),
+		exchangeType: exchangeType,
+		routingKey:   routingKey,
+		bindingKey:   bindingKey,
+		factory:      r,
 	}
 }
 
@@ -35,38 +96,85 @@ type amqpRequester struct {
 	payloadSize  int
 	queueName    string
 	exchangeName string
+	exchangeType string
+	routingKey   string
+	bindingKey   string
+	factory      *AMQPRequesterFactory
 	conn         *amqp.Connection
 	queue        amqp.Queue
 	channel      *amqp.Channel
 	inbound      <-chan amqp.Delivery
+	confirms     chan amqp.Confirmation
+	returns      chan amqp.Return
 	msg          amqp.Publishing
+	returned     uint64
+	nacked       uint64
+}
+
+// durable returns whether the exchange and queue are durable.
+func (r *amqpRequester) durable() bool {
+	return r.factory.Durable || r.factory.QueueType == "quorum"
 }
 
 // Setup prepares the Requester for benchmarking.
 func (r *amqpRequester) Setup() error {
+	var args amqp.Table
+	switch r.factory.QueueType {
+	case "", "classic":
+	case "quorum":
+		args = amqp.Table{"x-queue-type": "quorum"}
+	default:
+		return fmt.Errorf("requester: invalid AMQP QueueType %q", r.factory.QueueType)
+	}
+
 	conn, err := amqp.Dial(r.url)
 	if err != nil {
 		return err
 	}
+	if err := r.setup(conn, args); err != nil {
+		conn.Close()
+		return err
+	}
+	r.conn = conn
+	r.returned = 0
+	r.nacked = 0
+
+	deliveryMode := amqp.Transient
+	if r.factory.Persistent {
+		deliveryMode = amqp.Persistent
+	}
+	r.msg = amqp.Publishing{
+		Headers:      r.factory.Headers,
+		DeliveryMode: deliveryMode,
+		Timestamp:    time.Now(),
+		ContentType:  "text/plain",
+		Body:         make([]byte, r.payloadSize),
+	}
+	return nil
+}
+
+// setup declares the exchange and queue and starts consuming.
+func (r *amqpRequester) setup(conn *amqp.Connection, args amqp.Table) error {
 	c, err := conn.Channel()
 	if err != nil {
 		return err
 	}
+	durable := r.durable()
 	queue, err := c.QueueDeclare(
 		r.queueName, // name
-		false,       // not durable
+		durable,     // durable
 		false,       // delete when unused
-		true,        // exclusive
+		!durable,    // exclusive
 		false,       // no wait
-		nil,         // arguments
+		args,        // arguments
 	)
 	if err != nil {
 		return err
 	}
 	err = c.ExchangeDeclare(
 		r.exchangeName, // name
-		"fanout",       // type
-		false,          // not durable
+		r.exchangeType, // type
+		durable,        // durable
 		false,          // auto-deleted
 		false,          // internal
 		false,          // no wait
@@ -77,61 +185,132 @@ func (r *amqpRequester) Setup() error {
 	}
 	err = c.QueueBind(
 		r.queueName,
-		r.queueName,
+		r.bindingKey,
 		r.exchangeName,
 		false,
-		nil,
+		r.factory.BindingArgs,
 	)
 	if err != nil {
 		return err
 	}
 
-	inbound, err := c.Consume(
-		r.queueName, // queue
-		"",          // consumer
-		true,        // auto ack
-		false,       // exclusive
-		true,        // no local
-		false,       // no wait
-		nil,         // args
-	)
-	if err != nil {
-		return err
+	if r.factory.Confirm {
+		if err := c.Confirm(false); err != nil {
+			return err
+		}
+		r.confirms = c.NotifyPublish(make(chan amqp.Confirmation, 1))
+	}
+	if r.factory.Mandatory {
+		r.returns = c.NotifyReturn(make(chan amqp.Return, 1))
+	}
+
+	var inbound <-chan amqp.Delivery
+	if !r.factory.PublishOnly {
+		if r.factory.Prefetch > 0 {
+			if err := c.Qos(r.factory.Prefetch, 0, false); err != nil {
+				return err
+			}
+		}
+		inbound, err = c.Consume(
+			r.queueName,          // queue
+			"",                   // consumer
+			!r.factory.ManualAck, // auto ack
+			false,                // exclusive
+			true,                 // no local
+			false,                // no wait
+			nil,                  // args
+		)
+		if err != nil {
+			return err
+		}
 	}
-	r.conn = conn
 	r.queue = queue
 	r.channel = c
 	r.inbound = inbound
-	r.msg = amqp.Publishing{
-		DeliveryMode: amqp.Transient,
-		Timestamp:    time.Now(),
-		ContentType:  "text/plain",
-		Body:         make([]byte, r.payloadSize),
-	}
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (r *amqpRequester) Request() error {
 	if err := r.channel.Publish(
-		r.exchangeName, // exchange
-		"",             // routing key
-		false,          // mandatory
-		false,          // immediate
+		r.exchangeName,      // exchange
+		r.routingKey,        // routing key
+		r.factory.Mandatory, // mandatory
+		false,               // immediate
 		r.msg,
 	); err != nil {
 		return err
 	}
+
+	if r.confirms != nil {
+		select {
+		case confirm, ok := <-r.confirms:
+			if !ok {
+				return errors.New("requester: channel closed awaiting confirm")
+			}
+			if !confirm.Ack {
+				r.nacked++
+				return errors.New("requester: message nacked by broker")
+			}
+		case <-time.After(30 * time.Second):
+			return errors.New("requester: Request timed out awaiting confirm")
+		}
+		// Returns are sent before the confirm of the message.
+		select {
+		case ret := <-r.returns:
+			return r.returnedError(ret)
+		default:
+		}
+	}
+
+	if r.factory.PublishOnly {
+		// Returns must be received even though the request doesn't wait for
+		// them, or they block the connection.
+		select {
+		case ret := <-r.returns:
+			return r.returnedError(ret)
+		default:
+			return nil
+		}
+	}
 	select {
-	case <-r.inbound:
+	case delivery := <-r.inbound:
+		if r.factory.ManualAck {
+			return delivery.Ack(false)
+		}
+	case ret := <-r.returns:
+		return r.returnedError(ret)
 	case <-time.After(30 * time.Second):
 		return errors.New("requester: Request timed out receiving")
 	}
 	return nil
 }
 
+// returnedError counts a message returned by the broker and returns the
+// error for it.
+func (r *amqpRequester) returnedError(ret amqp.Return) error {
+	r.returned++
+	return fmt.Errorf("requester: message returned by broker: %s", ret.ReplyText)
+}
+
+// Report returns the number of returned and nacked messages.
+func (r *amqpRequester) Report() *bench.Report {
+	return &bench.Report{Counters: map[string]uint64{
+		"amqp.returned": r.returned,
+		"amqp.nacked":   r.nacked,
+	}}
+}
+
 // Teardown is called upon benchmark completion.
 func (r *amqpRequester) Teardown() error {
+	if r.durable() {
+		if _, err := r.channel.QueueDelete(r.queueName, false, false, false); err != nil {
+			return err
+		}
+		if err := r.channel.ExchangeDelete(r.exchangeName, false, false); err != nil {
+			return err
+		}
+	}
 	if err := r.channel.Close(); err != nil {
 		return err
 	}
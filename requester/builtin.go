@@ -0,0 +1,378 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ssd532/bench/v2"
+)
+
+// init registers the requesters of this package.
+func init() {
+	Register("amqp", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &AMQPRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Queue == "":
+			return nil, errMissing("Queue")
+		case f.Exchange == "":
+			return nil, errMissing("Exchange")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("cassandra", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &CassandraRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case len(f.URLs) == 0:
+			return nil, errMissing("URLs")
+		case f.Statement == "" && len(f.Statements) == 0:
+			return nil, errMissing("Statement or Statements")
+		}
+		return f, nil
+	})
+	Register("chain", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		var c struct {
+			Steps []struct {
+				Name      string
+				Requester string
+				Config    map[string]interface{}
+			}
+		}
+		if err := DecodeConfig(cfg, &c); err != nil {
+			return nil, err
+		}
+		f := &ChainRequesterFactory{}
+		for _, step := range c.Steps {
+			factory, err := NewFactory(step.Requester, step.Config)
+			if err != nil {
+				return nil, err
+			}
+			f.Steps = append(f.Steps, ChainStep{Name: step.Name, Factory: factory})
+		}
+		if err := f.validate(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	Register("grpc", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &GRPCRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.Target == "":
+			return nil, errMissing("Target")
+		case f.Method == "":
+			return nil, errMissing("Method")
+		case len(f.DescriptorSet) == 0 && !f.UseReflection:
+			return nil, errors.New("DescriptorSet or UseReflection is required")
+		}
+		return f, nil
+	})
+	Register("jetstream", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &JetStreamRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Stream == "":
+			return nil, errMissing("Stream")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("kafka", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &KafkaRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case len(f.URLs) == 0:
+			return nil, errMissing("URLs")
+		case f.Topic == "":
+			return nil, errMissing("Topic")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("liftbridge", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &LiftbridgeRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case len(f.URLs) == 0:
+			return nil, errMissing("URLs")
+		case f.Stream == "":
+			return nil, errMissing("Stream")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("mix", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		var c struct {
+			Members []struct {
+				Name      string
+				Weight    uint64
+				Requester string
+				Config    map[string]interface{}
+			}
+		}
+		if err := DecodeConfig(cfg, &c); err != nil {
+			return nil, err
+		}
+		f := &MixRequesterFactory{}
+		for _, member := range c.Members {
+			factory, err := NewFactory(member.Requester, member.Config)
+			if err != nil {
+				return nil, err
+			}
+			f.Members = append(f.Members, MixMember{Name: member.Name, Factory: factory, Weight: member.Weight})
+		}
+		if err := f.validate(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	Register("nats", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &NATSRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Subject == "":
+			return nil, errMissing("Subject")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("nats-streaming", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &NATSStreamingRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Subject == "":
+			return nil, errMissing("Subject")
+		case f.ClientID == "":
+			return nil, errMissing("ClientID")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		if _, err := f.subscriptionOptions(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	Register("noop", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &NOOPRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	Register("nsq", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &NSQRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Topic == "":
+			return nil, errMissing("Topic")
+		case f.MultiPublish > 1 && f.Delay > 0:
+			return nil, errors.New("MultiPublish and Delay can't be combined")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("redis", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &RedisRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Command == "":
+			return nil, errMissing("Command")
+		}
+		return f, nil
+	})
+	Register("redis-pubsub", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &RedisPubSubRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Channel == "":
+			return nil, errMissing("Channel")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("redis-streams", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &RedisStreamsRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.Stream == "":
+			return nil, errMissing("Stream")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("redis-workload", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &RedisWorkloadRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case len(f.Commands) == 0:
+			return nil, errMissing("Commands")
+		}
+		for _, command := range f.Commands {
+			if _, ok := redisKeyPrefixes[strings.ToUpper(command.Command)]; !ok {
+				return nil, fmt.Errorf("unsupported Redis command %q", command.Command)
+			}
+		}
+		return f, nil
+	})
+	Register("rmqstream", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &RMQStreamRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case len(f.URLs) == 0:
+			return nil, errMissing("URLs")
+		case f.Stream == "":
+			return nil, errMissing("Stream")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		if _, err := f.offset(false); err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	Register("simulated", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &SimulatedRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.ErrorRate < 0 || f.ErrorRate > 1:
+			return nil, fmt.Errorf("ErrorRate %v isn't in [0, 1]", f.ErrorRate)
+		case f.ArrivalRate < 0:
+			return nil, errNegative("ArrivalRate")
+		}
+		return f, nil
+	})
+	Register("sql", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &SQLRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.DriverName == "":
+			return nil, errMissing("DriverName")
+		case f.DSN == "":
+			return nil, errMissing("DSN")
+		case len(f.Statements) == 0:
+			return nil, errMissing("Statements")
+		}
+		return f, nil
+	})
+	Register("tcp", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &TCPRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.Address == "":
+			return nil, errMissing("Address")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("udp", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &UDPRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.Address == "":
+			return nil, errMissing("Address")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+	Register("web", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &WebRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		if f.URL == "" {
+			return nil, errMissing("URL")
+		}
+		return f, nil
+	})
+	Register("websocket", func(cfg map[string]interface{}) (bench.RequesterFactory, error) {
+		f := &WebSocketRequesterFactory{}
+		if err := DecodeConfig(cfg, f); err != nil {
+			return nil, err
+		}
+		switch {
+		case f.URL == "":
+			return nil, errMissing("URL")
+		case f.PayloadSize < 0:
+			return nil, errNegative("PayloadSize")
+		}
+		return f, nil
+	})
+}
+
+// errMissing returns the error for a required configuration field which isn't
+// set.
+func errMissing(field string) error {
+	return fmt.Errorf("%s is required", field)
+}
+
+// errNegative returns the error for a configuration field which is negative.
+func errNegative(field string) error {
+	return fmt.Errorf("%s can't be negative", field)
+}
@@ -1,28 +1,116 @@
 package requester
 
 import (
+	"sync"
+
 	"github.com/gocql/gocql"
 	"github.com/ssd532/bench/v2"
 )
 
+// CassandraStatement is a statement issued by the Cassandra requester as part
+// of a weighted mix of statements.
+type CassandraStatement struct {
+	// Name labels the statement's results in the Summary. Defaults to the
+	// statement text.
+	Name string
+
+	// Statement is the CQL statement. Statements with values are prepared by
+	// gocql on first use.
+	Statement string
+
+	// Values are bound to the statement. Values which are ValueGenerators
+	// are evaluated for every request.
+	Values []interface{}
+
+	// Weight is the relative frequency of the statement in the mix. A zero
+	// weight is treated as 1.
+	Weight uint64
+
+	// BatchSize, if greater than zero, issues the statement BatchSize times
+	// as a single batch of type BatchType, generating values for each.
+	BatchSize int
+	BatchType gocql.BatchType
+
+	// PageSize sets the number of rows fetched per page. All pages of the
+	// result are read. Zero means the gocql default.
+	PageSize int
+}
+
+// name returns the label of the statement.
+func (c *CassandraStatement) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Statement
+}
+
 // CassandraRequesterFactory implements RequesterFactory by creating a
 // Requester which issues queries to Cassandra. Works with Cassandra 2.x.x.
+//
+// If Statements is empty, every request issues Statement with Values.
+// Otherwise every request issues one of Statements, chosen by weight, and the
+// results are recorded per statement in Summary.Labels.
 type CassandraRequesterFactory struct {
 	URLs        []string
 	Keyspace    string
 	Consistency gocql.Consistency
 	Statement   string
 	Values      []interface{}
+	Statements  []CassandraStatement
+
+	// SharedSession uses a single session, and so a single connection pool,
+	// for all Benchmark connections rather than a session per connection.
+	SharedSession bool
+
+	mu       sync.Mutex
+	session  *gocql.Session
+	sessions int
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (c *CassandraRequesterFactory) GetRequester(uint64) bench.Requester {
+	statements := c.Statements
+	if len(statements) == 0 {
+		statements = []CassandraStatement{{Statement: c.Statement, Values: c.Values}}
+	}
+	weights := make([]uint64, len(statements))
+	for i, statement := range statements {
+		weights[i] = statement.Weight
+	}
 	return &cassandraRequester{
 		urls:        c.URLs,
 		keyspace:    c.Keyspace,
 		consistency: c.Consistency,
-		statement:   c.Statement,
-		values:      c.Values,
+		statements:  statements,
+		choice:      newWeightedChoice(weights),
+		factory:     c,
+	}
+}
+
+// acquireSession returns the shared session, creating it if necessary.
+func (c *CassandraRequesterFactory) acquireSession(r *cassandraRequester) (*gocql.Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil {
+		session, err := r.newSession()
+		if err != nil {
+			return nil, err
+		}
+		c.session = session
+	}
+	c.sessions++
+	return c.session, nil
+}
+
+// releaseSession releases the shared session, closing it when no Benchmark
+// connections are using it anymore.
+func (c *CassandraRequesterFactory) releaseSession() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions--
+	if c.sessions == 0 && c.session != nil {
+		c.session.Close()
+		c.session = nil
 	}
 }
 
@@ -32,32 +120,86 @@ type cassandraRequester struct {
 	urls        []string
 	keyspace    string
 	consistency gocql.Consistency
-	statement   string
-	values      []interface{}
+	statements  []CassandraStatement
+	choice      *weightedChoice
+	factory     *CassandraRequesterFactory
 	session     *gocql.Session
+	last        int
+	rows        uint64
 }
 
 // Setup prepares the Requester for benchmarking.
 func (c *cassandraRequester) Setup() error {
-	cluster := gocql.NewCluster(c.urls...)
-	cluster.Keyspace = c.keyspace
-	cluster.Consistency = c.consistency
-	session, err := cluster.CreateSession()
+	var (
+		session *gocql.Session
+		err     error
+	)
+	if c.factory.SharedSession {
+		session, err = c.factory.acquireSession(c)
+	} else {
+		session, err = c.newSession()
+	}
 	if err != nil {
 		return err
 	}
 	c.session = session
+	c.rows = 0
 	return nil
 }
 
+// newSession creates a session to the cluster.
+func (c *cassandraRequester) newSession() (*gocql.Session, error) {
+	cluster := gocql.NewCluster(c.urls...)
+	cluster.Keyspace = c.keyspace
+	cluster.Consistency = c.consistency
+	return cluster.CreateSession()
+}
+
 // Request performs a synchronous request to the system under test.
 func (c *cassandraRequester) Request() error {
-	return c.session.Query(c.statement, c.values...).Exec()
+	c.last = c.choice.choose()
+	statement := &c.statements[c.last]
+
+	if statement.BatchSize > 0 {
+		batch := c.session.NewBatch(statement.BatchType)
+		for i := 0; i < statement.BatchSize; i++ {
+			batch.Query(statement.Statement, generateValues(statement.Values)...)
+		}
+		return c.session.ExecuteBatch(batch)
+	}
+
+	query := c.session.Query(statement.Statement, generateValues(statement.Values)...)
+	if statement.PageSize > 0 {
+		query.PageSize(statement.PageSize)
+	}
+	scanner := query.Iter().Scanner()
+	for scanner.Next() {
+		c.rows++
+	}
+	return scanner.Err()
+}
+
+// Label returns the name of the last statement issued if a mix of statements
+// is configured.
+func (c *cassandraRequester) Label() string {
+	if len(c.statements) == 1 {
+		return ""
+	}
+	return c.statements[c.last].name()
+}
+
+// Report returns the number of rows read.
+func (c *cassandraRequester) Report() *bench.Report {
+	return &bench.Report{Counters: map[string]uint64{"cassandra.rows": c.rows}}
 }
 
 // Teardown is called upon benchmark completion.
 func (c *cassandraRequester) Teardown() error {
-	c.session.Close()
+	if c.factory.SharedSession {
+		c.factory.releaseSession()
+	} else {
+		c.session.Close()
+	}
 	c.session = nil
 	return nil
 }
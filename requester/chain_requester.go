@@ -0,0 +1,217 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ssd532/bench/v2"
+)
+
+// ChainValues carries values between the steps of a single chain request,
+// e.g. an ID generated in the first step and looked up in a later one. Kafka,
+// NATS and Redis steps use the values named by the KeyValue, SubjectValue and
+// ReplyValue fields of their factories.
+type ChainValues map[string]interface{}
+
+// ChainRequester may be implemented by a Requester used as a step of a
+// ChainRequesterFactory to exchange values with the other steps. ChainRequest
+// is then called instead of Request with the values of the current chain
+// request, which it may read and add to.
+type ChainRequester interface {
+	// ChainRequest performs a synchronous request to the system under test.
+	ChainRequest(values ChainValues) error
+}
+
+// ChainFunc returns a RequesterFactory for a chain step which calls fn with
+// the values of the chain request, e.g. to wait for a confirmation of an order
+// published in a previous step. fn is shared by all connections of a
+// Benchmark, so it must be safe for concurrent use.
+func ChainFunc(fn func(values ChainValues) error) bench.RequesterFactory {
+	return &chainFuncRequesterFactory{fn: fn}
+}
+
+// ChainStep is one step of the requests of a ChainRequesterFactory.
+type ChainStep struct {
+	// Name identifies the step in the results. It must be unique within the
+	// chain.
+	Name string
+
+	// Factory creates the step's Requesters, which may implement
+	// ChainRequester.
+	Factory bench.RequesterFactory
+}
+
+// ChainRequesterFactory implements RequesterFactory by creating a Requester
+// which performs the requests of an ordered list of steps as a single request,
+// e.g. to measure the latency of a user flow which publishes an order to
+// Kafka, waits for a confirmation on NATS and reads the resulting state from
+// Redis. A request fails at the first step which fails, and the remaining
+// steps are skipped.
+//
+// The latencies of the whole chain are the Benchmark's results. The latencies
+// of every successful step are reported as the "chain.<step>" histogram and
+// its failures as the "chain.<step>.errors" counter. Results reported by steps
+// implementing Reporter are prefixed with the step's name and a dot.
+type ChainRequesterFactory struct {
+	Steps []ChainStep
+
+	// Values are generated at the start of every chain request and passed to
+	// steps implementing ChainRequester under their names.
+	Values map[string]ValueGenerator
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (c *ChainRequesterFactory) GetRequester(num uint64) bench.Requester {
+	requesters := make([]bench.Requester, len(c.Steps))
+	for i, step := range c.Steps {
+		if step.Factory != nil {
+			requesters[i] = step.Factory.GetRequester(num)
+		}
+	}
+	return &chainRequester{
+		steps:      c.Steps,
+		requesters: requesters,
+		factory:    c,
+	}
+}
+
+// validate returns an error if the steps are misconfigured.
+func (c *ChainRequesterFactory) validate() error {
+	if len(c.Steps) == 0 {
+		return errors.New("no chain steps configured")
+	}
+	names := make(map[string]bool, len(c.Steps))
+	for _, step := range c.Steps {
+		if step.Name == "" {
+			return errors.New("chain step without a name")
+		}
+		if step.Factory == nil {
+			return fmt.Errorf("chain step %q has no Factory", step.Name)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate chain step %q", step.Name)
+		}
+		names[step.Name] = true
+	}
+	return nil
+}
+
+// chainRequester implements Requester by performing the requests of all
+// steps in order.
+type chainRequester struct {
+	steps      []ChainStep
+	requesters []bench.Requester
+	factory    *ChainRequesterFactory
+	histograms []*hdrhistogram.Histogram
+	errors     []uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (c *chainRequester) Setup() error {
+	if err := c.factory.validate(); err != nil {
+		return fmt.Errorf("requester: %v", err)
+	}
+	for i, requester := range c.requesters {
+		if err := requester.Setup(); err != nil {
+			for _, r := range c.requesters[:i] {
+				r.Teardown()
+			}
+			return fmt.Errorf("requester: chain step %q: %v", c.steps[i].Name, err)
+		}
+	}
+	c.histograms = make([]*hdrhistogram.Histogram, len(c.steps))
+	for i := range c.histograms {
+		c.histograms[i] = bench.NewHistogram()
+	}
+	c.errors = make([]uint64, len(c.steps))
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (c *chainRequester) Request() error {
+	values := make(ChainValues, len(c.factory.Values))
+	for name, generator := range c.factory.Values {
+		values[name] = generator()
+	}
+	for i, requester := range c.requesters {
+		start := time.Now()
+		var err error
+		if chained, ok := requester.(ChainRequester); ok {
+			err = chained.ChainRequest(values)
+		} else {
+			err = requester.Request()
+		}
+		if err != nil {
+			c.errors[i]++
+			return fmt.Errorf("requester: chain step %q: %v", c.steps[i].Name, err)
+		}
+		c.histograms[i].RecordValue(time.Since(start).Nanoseconds())
+	}
+	return nil
+}
+
+// Report returns the latencies and failures of every step and the results
+// reported by the steps, prefixed with their names.
+func (c *chainRequester) Report() *bench.Report {
+	report := &bench.Report{
+		Histograms: make(map[string]*hdrhistogram.Histogram),
+		Counters:   make(map[string]uint64),
+		Metadata:   make(map[string]string),
+	}
+	for i, requester := range c.requesters {
+		name := c.steps[i].Name
+		report.Histograms["chain."+name] = c.histograms[i]
+		report.Counters["chain."+name+".errors"] = c.errors[i]
+		addPrefixedReport(report, name+".", requester)
+	}
+	return report
+}
+
+// Teardown is called upon benchmark completion.
+func (c *chainRequester) Teardown() error {
+	var firstErr error
+	for i, requester := range c.requesters {
+		if err := requester.Teardown(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("requester: chain step %q: %v", c.steps[i].Name, err)
+		}
+	}
+	return firstErr
+}
+
+// chainFuncRequesterFactory implements RequesterFactory by creating a
+// Requester which calls a function as a chain step.
+type chainFuncRequesterFactory struct {
+	fn func(values ChainValues) error
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (c *chainFuncRequesterFactory) GetRequester(uint64) bench.Requester {
+	return &chainFuncRequester{fn: c.fn}
+}
+
+// chainFuncRequester implements ChainRequester by calling a function.
+type chainFuncRequester struct {
+	fn func(values ChainValues) error
+}
+
+// Setup prepares the Requester for benchmarking.
+func (c *chainFuncRequester) Setup() error {
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (c *chainFuncRequester) Request() error {
+	return c.fn(ChainValues{})
+}
+
+// ChainRequest performs a synchronous request to the system under test.
+func (c *chainFuncRequester) ChainRequest(values ChainValues) error {
+	return c.fn(values)
+}
+
+// Teardown is called upon benchmark completion.
+func (c *chainFuncRequester) Teardown() error {
+	return nil
+}
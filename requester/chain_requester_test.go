@@ -0,0 +1,61 @@
+package requester_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestChainRequesterValues(t *testing.T) {
+	redis := requestertest.Redis(t)
+	factory := &requester.ChainRequesterFactory{
+		Steps: []requester.ChainStep{
+			{
+				Name: "confirm",
+				Factory: &requester.NATSRequesterFactory{
+					URL:          requestertest.NATS(t),
+					Subject:      "orders",
+					Mode:         requester.NATSRequestReply,
+					Responders:   1,
+					SubjectValue: "id",
+				},
+			},
+			{
+				Name: "store",
+				Factory: &requester.RedisRequesterFactory{
+					URL:      redis,
+					Command:  "SET",
+					Args:     []interface{}{"key", "stored"},
+					KeyValue: "id",
+				},
+			},
+			{
+				Name: "load",
+				Factory: &requester.RedisRequesterFactory{
+					URL:        redis,
+					Command:    "GET",
+					KeyValue:   "id",
+					ReplyValue: "state",
+				},
+			},
+			{
+				Name: "check",
+				Factory: requester.ChainFunc(func(values requester.ChainValues) error {
+					if state, ok := values["state"].([]byte); !ok || string(state) != "stored" {
+						return fmt.Errorf("got state %v for order %v", values["state"], values["id"])
+					}
+					return nil
+				}),
+			},
+		},
+		Values: map[string]requester.ValueGenerator{"id": requester.Sequential(1)},
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	if got := summary.Counters["chain.load.errors"]; got != 0 {
+		t.Errorf("got %d load errors, want 0", got)
+	}
+}
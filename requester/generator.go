@@ -0,0 +1,211 @@
+package requester
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ValueGenerator returns a new value for every request, e.g. a key or a
+// column value. A ValueGenerator is shared by all connections of a Benchmark,
+// so it must be safe for concurrent use.
+type ValueGenerator func() interface{}
+
+// KeyGenerator returns a key in the range [0, n), n > 0, for every request. A
+// KeyGenerator is shared by all connections of a Benchmark, so it must be safe
+// for concurrent use.
+type KeyGenerator func() uint64
+
+// SequentialKeys returns a KeyGenerator which cycles through the keys in
+// [0, n) in order. SequentialKeys panics if n is zero.
+func SequentialKeys(n uint64) KeyGenerator {
+	if n == 0 {
+		panic("requester: SequentialKeys called with n = 0")
+	}
+	var next uint64
+	return func() uint64 {
+		return (atomic.AddUint64(&next, 1) - 1) % n
+	}
+}
+
+// UniformKeys returns a KeyGenerator which chooses keys in [0, n) uniformly
+// at random. UniformKeys panics if n is zero.
+func UniformKeys(n uint64) KeyGenerator {
+	if n == 0 {
+		panic("requester: UniformKeys called with n = 0")
+	}
+	r := newLockedRand()
+	return func() uint64 {
+		return r.uint64n(n)
+	}
+}
+
+// ZipfianKeys returns a KeyGenerator which chooses keys in [0, n) following a
+// Zipfian distribution with exponent s > 1, so lower keys are chosen more
+// often. A typical value for s is 1.1. ZipfianKeys panics if n is zero or if
+// s isn't greater than 1.
+func ZipfianKeys(n uint64, s float64) KeyGenerator {
+	if n == 0 {
+		panic("requester: ZipfianKeys called with n = 0")
+	}
+	if !(s > 1) {
+		panic(fmt.Sprintf("requester: ZipfianKeys called with s = %v, which isn't greater than 1", s))
+	}
+	r := newLockedRand()
+	zipf := rand.NewZipf(r.rand, s, 1, n-1)
+	return func() uint64 {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return zipf.Uint64()
+	}
+}
+
+// Keys returns a ValueGenerator producing the keys of the KeyGenerator as
+// int64 values.
+func Keys(keys KeyGenerator) ValueGenerator {
+	return func() interface{} {
+		return int64(keys())
+	}
+}
+
+// Sequential returns a ValueGenerator producing increasing int64 values
+// starting at start.
+func Sequential(start int64) ValueGenerator {
+	next := start - 1
+	return func() interface{} {
+		return atomic.AddInt64(&next, 1)
+	}
+}
+
+// UniformInt returns a ValueGenerator producing int64 values in [min, max)
+// uniformly at random. UniformInt panics if max isn't greater than min.
+func UniformInt(min, max int64) ValueGenerator {
+	if max <= min {
+		panic(fmt.Sprintf("requester: UniformInt called with max %d not greater than min %d", max, min))
+	}
+	r := newLockedRand()
+	return func() interface{} {
+		return min + int64(r.uint64n(uint64(max-min)))
+	}
+}
+
+// RandomBlob returns a ValueGenerator producing byte slices of the given size
+// filled with random data.
+func RandomBlob(size int) ValueGenerator {
+	r := newLockedRand()
+	return func() interface{} {
+		blob := make([]byte, size)
+		r.read(blob)
+		return blob
+	}
+}
+
+// RandomString returns a ValueGenerator producing strings of the given size
+// filled with random uppercase letters.
+func RandomString(size int) ValueGenerator {
+	return func() interface{} {
+		return string(randomPayload(size))
+	}
+}
+
+// FixedSize returns a size generator which always returns size.
+func FixedSize(size int) func() int {
+	return func() int { return size }
+}
+
+// UniformSize returns a size generator which returns sizes in [min, max]
+// uniformly at random. UniformSize panics if max is less than min.
+func UniformSize(min, max int) func() int {
+	if max < min {
+		panic(fmt.Sprintf("requester: UniformSize called with max %d less than min %d", max, min))
+	}
+	r := newLockedRand()
+	return func() int {
+		return min + int(r.uint64n(uint64(max-min+1)))
+	}
+}
+
+// generateValues returns the values with any ValueGenerators replaced by a
+// generated value.
+func generateValues(values []interface{}) []interface{} {
+	generated := values
+	copied := false
+	for i, value := range values {
+		generator, ok := value.(ValueGenerator)
+		if !ok {
+			continue
+		}
+		if !copied {
+			generated = make([]interface{}, len(values))
+			copy(generated, values)
+			copied = true
+		}
+		generated[i] = generator()
+	}
+	return generated
+}
+
+// weightedChoice chooses indexes at random in proportion to their weights.
+// Zero weights are treated as a weight of 1.
+type weightedChoice struct {
+	cumulative []uint64
+	rand       *rand.Rand
+}
+
+// newWeightedChoice returns a weightedChoice for the given weights. It's not
+// safe for concurrent use.
+func newWeightedChoice(weights []uint64) *weightedChoice {
+	cumulative := make([]uint64, len(weights))
+	var total uint64
+	for i, weight := range weights {
+		if weight == 0 {
+			weight = 1
+		}
+		total += weight
+		cumulative[i] = total
+	}
+	return &weightedChoice{
+		cumulative: cumulative,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// choose returns the next index.
+func (w *weightedChoice) choose() int {
+	if len(w.cumulative) == 1 {
+		return 0
+	}
+	n := uint64(w.rand.Int63n(int64(w.cumulative[len(w.cumulative)-1])))
+	return sort.Search(len(w.cumulative), func(i int) bool { return w.cumulative[i] > n })
+}
+
+// lockedRand is a rand.Rand which is safe for concurrent use.
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// newLockedRand returns a lockedRand seeded with the current time.
+func newLockedRand() *lockedRand {
+	return &lockedRand{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// uint64n returns a uint64 in [0, n).
+func (r *lockedRand) uint64n(n uint64) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n < 1<<63 {
+		return uint64(r.rand.Int63n(int64(n)))
+	}
+	return r.rand.Uint64() % n
+}
+
+// read fills the buffer with random data.
+func (r *lockedRand) read(buf []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rand.Read(buf)
+}
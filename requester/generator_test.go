@@ -0,0 +1,65 @@
+package requester_test
+
+import (
+	"testing"
+
+	"github.com/ssd532/bench/v2/requester"
+)
+
+func TestZipfianKeys(t *testing.T) {
+	keys := requester.ZipfianKeys(10, 1.1)
+	for i := 0; i < 1000; i++ {
+		if key := keys(); key >= 10 {
+			t.Fatalf("got key %d, want less than 10", key)
+		}
+	}
+	keys = requester.ZipfianKeys(1, 1.1)
+	if key := keys(); key != 0 {
+		t.Fatalf("got key %d, want 0", key)
+	}
+}
+
+func TestUniformInt(t *testing.T) {
+	values := requester.UniformInt(-5, 5)
+	for i := 0; i < 1000; i++ {
+		if value := values().(int64); value < -5 || value >= 5 {
+			t.Fatalf("got value %d, want a value in [-5, 5)", value)
+		}
+	}
+}
+
+func TestUniformSize(t *testing.T) {
+	sizes := requester.UniformSize(3, 5)
+	for i := 0; i < 1000; i++ {
+		if size := sizes(); size < 3 || size > 5 {
+			t.Fatalf("got size %d, want a size in [3, 5]", size)
+		}
+	}
+	if size := requester.UniformSize(4, 4)(); size != 4 {
+		t.Fatalf("got size %d, want 4", size)
+	}
+}
+
+func TestGeneratorsPanic(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fn   func()
+	}{
+		{"SequentialKeys n = 0", func() { requester.SequentialKeys(0) }},
+		{"UniformKeys n = 0", func() { requester.UniformKeys(0) }},
+		{"ZipfianKeys n = 0", func() { requester.ZipfianKeys(0, 1.1) }},
+		{"ZipfianKeys s = 1", func() { requester.ZipfianKeys(10, 1) }},
+		{"UniformInt max = min", func() { requester.UniformInt(5, 5) }},
+		{"UniformInt max < min", func() { requester.UniformInt(5, -5) }},
+		{"UniformSize max < min", func() { requester.UniformSize(5, 4) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("didn't panic")
+				}
+			}()
+			test.fn()
+		})
+	}
+}
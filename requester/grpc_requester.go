@@ -0,0 +1,372 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCRequesterFactory implements RequesterFactory by creating a Requester
+// which invokes a gRPC method and waits for the response. Each Benchmark
+// connection uses its own grpc.ClientConn.
+//
+// Request and response messages are either created by NewRequest and
+// NewResponse, typically returning generated message types, or resolved from
+// a descriptor set or server reflection, in which case the request is decoded
+// from RequestJSON.
+type GRPCRequesterFactory struct {
+	Target string
+
+	// Method is the full name of the method to invoke, e.g.
+	// "/helloworld.Greeter/SayHello".
+	Method string
+
+	// DialOptions are used when dialing Target. If empty, an insecure
+	// connection is used.
+	DialOptions []grpc.DialOption
+
+	// Metadata is sent with every call or stream.
+	Metadata map[string]string
+
+	// Timeout limits the time taken by a single call. Zero means no timeout.
+	// It's not applied to streams.
+	Timeout time.Duration
+
+	// Streaming causes each request to send one message on a bidirectional
+	// stream opened in Setup and wait for one response message. After a
+	// failed request, the stream is reopened. It's determined from the
+	// method descriptor when using DescriptorSet or UseReflection, which
+	// don't support client-only or server-only streaming methods.
+	Streaming bool
+
+	// NewRequest and NewResponse return the request message sent with every
+	// call and the message responses are decoded into, e.g. generated
+	// protobuf message types.
+	NewRequest  func() interface{}
+	NewResponse func() interface{}
+
+	// DescriptorSet is a serialized FileDescriptorSet, as produced by
+	// protoc --descriptor_set_out --include_imports, containing the method.
+	DescriptorSet []byte
+
+	// UseReflection resolves the method using the server reflection service.
+	UseReflection bool
+
+	// RequestJSON is the JSON encoded request message used with
+	// DescriptorSet or UseReflection.
+	RequestJSON string
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (g *GRPCRequesterFactory) GetRequester(uint64) bench.Requester {
+	return &grpcRequester{
+		target:        g.Target,
+		method:        g.Method,
+		dialOptions:   g.DialOptions,
+		metadata:      g.Metadata,
+		timeout:       g.Timeout,
+		streaming:     g.Streaming,
+		newRequest:    g.NewRequest,
+		newResponse:   g.NewResponse,
+		descriptorSet: g.DescriptorSet,
+		useReflection: g.UseReflection,
+		requestJSON:   g.RequestJSON,
+	}
+}
+
+// grpcRequester implements Requester by invoking a gRPC method and waiting for
+// the response.
+type grpcRequester struct {
+	target        string
+	method        string
+	dialOptions   []grpc.DialOption
+	metadata      map[string]string
+	timeout       time.Duration
+	streaming     bool
+	newRequest    func() interface{}
+	newResponse   func() interface{}
+	descriptorSet []byte
+	useReflection bool
+	requestJSON   string
+	conn          *grpc.ClientConn
+	stream        grpc.ClientStream
+	cancel        context.CancelFunc
+	req           interface{}
+	resp          interface{}
+	codes         map[string]uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (g *grpcRequester) Setup() error {
+	options := g.dialOptions
+	if len(options) == 0 {
+		options = []grpc.DialOption{grpc.WithInsecure()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, g.target, append(options, grpc.WithBlock())...)
+	if err != nil {
+		return err
+	}
+
+	if err := g.prepareMessages(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	g.conn = conn
+	if g.streaming {
+		if err := g.openStream(); err != nil {
+			conn.Close()
+			g.conn = nil
+			return err
+		}
+	}
+	g.codes = make(map[string]uint64)
+	return nil
+}
+
+// openStream opens the bidirectional stream used by streaming requests.
+func (g *grpcRequester) openStream() error {
+	ctx, cancel := context.WithCancel(g.context())
+	stream, err := g.conn.NewStream(ctx, &grpc.StreamDesc{
+		StreamName:    g.method,
+		ClientStreams: true,
+		ServerStreams: true,
+	}, g.method)
+	if err != nil {
+		cancel()
+		return err
+	}
+	g.stream = stream
+	g.cancel = cancel
+	return nil
+}
+
+// prepareMessages creates the request and response messages, resolving the
+// method's descriptor if necessary.
+func (g *grpcRequester) prepareMessages(conn *grpc.ClientConn) error {
+	if g.newRequest != nil {
+		if g.newResponse == nil {
+			return errors.New("requester: NewResponse is required with NewRequest")
+		}
+		g.req = g.newRequest()
+		g.resp = g.newResponse()
+		return nil
+	}
+
+	var (
+		files *protoregistry.Files
+		err   error
+	)
+	switch {
+	case len(g.descriptorSet) > 0:
+		files, err = filesFromDescriptorSet(g.descriptorSet)
+	case g.useReflection:
+		files, err = filesFromReflection(conn, grpcServiceName(g.method))
+	default:
+		return errors.New("requester: one of NewRequest, DescriptorSet or UseReflection is required")
+	}
+	if err != nil {
+		return err
+	}
+	method, err := findMethod(files, g.method)
+	if err != nil {
+		return err
+	}
+	if method.IsStreamingClient() != method.IsStreamingServer() {
+		return fmt.Errorf("requester: method %q is neither unary nor bidirectional streaming", g.method)
+	}
+	g.streaming = method.IsStreamingClient()
+
+	req := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal([]byte(g.requestJSON), req); err != nil {
+		return fmt.Errorf("requester: invalid RequestJSON: %v", err)
+	}
+	g.req = req
+	g.resp = dynamicpb.NewMessage(method.Output())
+	return nil
+}
+
+// context returns the base context for calls, including metadata.
+func (g *grpcRequester) context() context.Context {
+	ctx := context.Background()
+	if len(g.metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(g.metadata))
+	}
+	return ctx
+}
+
+// Request performs a synchronous request to the system under test.
+func (g *grpcRequester) Request() error {
+	var err error
+	if g.streaming {
+		if g.stream == nil {
+			err = g.openStream()
+		}
+		if err == nil {
+			if err = g.stream.SendMsg(g.req); err == nil {
+				err = g.stream.RecvMsg(g.resp)
+			}
+		}
+		if err != nil && g.stream != nil {
+			// A failed stream can't be used anymore, so the next request
+			// opens a new one.
+			g.cancel()
+			g.stream = nil
+			g.cancel = nil
+		}
+	} else {
+		ctx := g.context()
+		if g.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, g.timeout)
+			defer cancel()
+		}
+		err = g.conn.Invoke(ctx, g.method, g.req, g.resp)
+	}
+	g.codes[status.Code(err).String()]++
+	return err
+}
+
+// Report returns the number of calls completed with each status code.
+func (g *grpcRequester) Report() *bench.Report {
+	report := &bench.Report{Counters: map[string]uint64{}}
+	for code, count := range g.codes {
+		report.Counters["status."+code] = count
+	}
+	return report
+}
+
+// Teardown is called upon benchmark completion. It always cancels the stream
+// and closes the connection, returning the first error encountered.
+func (g *grpcRequester) Teardown() error {
+	var firstErr error
+	if g.stream != nil {
+		firstErr = g.stream.CloseSend()
+		g.cancel()
+		g.stream = nil
+		g.cancel = nil
+	}
+	if err := g.conn.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	g.conn = nil
+	return firstErr
+}
+
+// grpcServiceName returns the fully-qualified service name of a full method
+// name, e.g. "helloworld.Greeter" for "/helloworld.Greeter/SayHello".
+func grpcServiceName(method string) string {
+	method = strings.TrimPrefix(method, "/")
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		return method[:i]
+	}
+	return method
+}
+
+// findMethod resolves the descriptor of a full method name.
+func findMethod(files *protoregistry.Files, method string) (protoreflect.MethodDescriptor, error) {
+	service := grpcServiceName(method)
+	name := method[strings.LastIndex(method, "/")+1:]
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("requester: service %q not found: %v", service, err)
+	}
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("requester: %q is not a service", service)
+	}
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(name))
+	if methodDesc == nil {
+		return nil, fmt.Errorf("requester: method %q not found in service %q", name, service)
+	}
+	return methodDesc, nil
+}
+
+// filesFromDescriptorSet builds a file registry from a serialized
+// FileDescriptorSet.
+func filesFromDescriptorSet(data []byte) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("requester: invalid descriptor set: %v", err)
+	}
+	return protodesc.NewFiles(&set)
+}
+
+// filesFromReflection builds a file registry containing the given service
+// and its dependencies using the server reflection service.
+func filesFromReflection(conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	var (
+		files     = make(map[string]*descriptorpb.FileDescriptorProto)
+		requested = make(map[string]bool)
+		pending   = []*rpb.ServerReflectionRequest{{
+			MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+		}}
+	)
+	for len(pending) > 0 {
+		req := pending[0]
+		pending = pending[1:]
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, errors.New("requester: reflection stream closed unexpectedly")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			return nil, fmt.Errorf("requester: reflection error: %s", errResp.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, err
+			}
+			files[file.GetName()] = file
+		}
+		for _, file := range files {
+			for _, dep := range file.GetDependency() {
+				if _, ok := files[dep]; ok || requested[dep] {
+					continue
+				}
+				requested[dep] = true
+				pending = append(pending, &rpb.ServerReflectionRequest{
+					MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+				})
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		set.File = append(set.File, file)
+	}
+	return protodesc.NewFiles(set)
+}
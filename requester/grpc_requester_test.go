@@ -0,0 +1,96 @@
+package requester_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestGRPCRequesterUnary(t *testing.T) {
+	factory := &requester.GRPCRequesterFactory{
+		Target:        requestertest.GRPC(t),
+		Method:        "/grpc.health.v1.Health/Check",
+		UseReflection: true,
+		RequestJSON:   `{}`,
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	if got := summary.Counters["status.OK"]; got != summary.SuccessTotal {
+		t.Errorf("got %d OK calls, want %d", got, summary.SuccessTotal)
+	}
+}
+
+func TestGRPCRequesterBidiStreaming(t *testing.T) {
+	factory := &requester.GRPCRequesterFactory{
+		Target:        requestertest.GRPC(t),
+		Method:        "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+		UseReflection: true,
+		RequestJSON:   `{"listServices": ""}`,
+	}
+	requestertest.Check(t, factory, 10)
+	requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+}
+
+func TestGRPCRequesterServerStreamingUnsupported(t *testing.T) {
+	factory := &requester.GRPCRequesterFactory{
+		Target:        requestertest.GRPC(t),
+		Method:        "/grpc.health.v1.Health/Watch",
+		UseReflection: true,
+		RequestJSON:   `{}`,
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err == nil {
+		r.Teardown()
+		t.Fatal("Setup succeeded for a server streaming method")
+	}
+}
+
+func TestGRPCRequesterReopensStream(t *testing.T) {
+	// The server fails every stream after two messages.
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		for i := 0; ; i++ {
+			msg := new(emptypb.Empty)
+			if err := stream.RecvMsg(msg); err != nil {
+				return err
+			}
+			if i == 2 {
+				return status.Error(codes.Unavailable, "stream failed")
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	}))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Stop()
+
+	factory := &requester.GRPCRequesterFactory{
+		Target:      listener.Addr().String(),
+		Method:      "/test.Echo/Stream",
+		Streaming:   true,
+		NewRequest:  func() interface{} { return new(emptypb.Empty) },
+		NewResponse: func() interface{} { return new(emptypb.Empty) },
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	for i := 0; i < 6; i++ {
+		err := r.Request()
+		if failed := i%3 == 2; failed != (err != nil) {
+			t.Errorf("Request %d: got error %v", i, err)
+		}
+	}
+}
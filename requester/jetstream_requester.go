@@ -2,6 +2,7 @@ package requester
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -19,10 +20,41 @@ type JetStreamRequesterFactory struct {
 	Stream               string
 	AsyncPublish         bool
 	MaxPublishAckPending int // wont' be used if async false
+
+	// Storage, Replicas, Retention, MaxMsgs and MaxBytes configure the
+	// stream. They default to the server defaults: file storage, a single
+	// replica, limits retention and no limits.
+	Storage   nats.StorageType
+	Replicas  int
+	Retention nats.RetentionPolicy
+	MaxMsgs   int64
+	MaxBytes  int64
+
+	// DuplicateWindow, if greater than zero, sets the stream's deduplication
+	// window and publishes every message with a unique Nats-Msg-Id header.
+	DuplicateWindow time.Duration
+
+	// Pull uses a pull consumer rather than a push consumer. Every request
+	// publishes BatchSize messages, which defaults to 1, and waits to
+	// consume them, fetching them in a single batch with pull consumers.
+	Pull      bool
+	BatchSize int
+
+	// AckPolicy is the consumer's ack policy: "none", "all", which acks the
+	// last message of every request, or "explicit", which acks every
+	// message. Acks are synchronous. Defaults to "explicit", which is the
+	// only policy supported by pull consumers. AckWait, if greater than
+	// zero, is the time the server waits for an ack before redelivering.
+	AckPolicy string
+	AckWait   time.Duration
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (j *JetStreamRequesterFactory) GetRequester(num uint64) bench.Requester {
+	batchSize := j.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
 	return &jetstreamRequester{
 		url:                  j.URL,
 		payloadSize:          j.PayloadSize,
@@ -30,6 +62,8 @@ func (j *JetStreamRequesterFactory) GetRequester(num uint64) bench.Requester {
 		subject:              strings.ToUpper(j.Stream + "-" + strconv.FormatUint(num, 10) + ".subject"),
 		asyncPublish:         j.AsyncPublish,
 		maxPublishAckPending: j.MaxPublishAckPending,
+		batchSize:            batchSize,
+		factory:              j,
 	}
 }
 
@@ -44,37 +78,82 @@ type jetstreamRequester struct {
 	js                   nats.JetStreamContext
 	msg                  []byte
 	sub                  *nats.Subscription
-	inbound              chan nats.Msg
+	inbound              chan *nats.Msg
 	asyncPublish         bool
 	maxPublishAckPending int
+	batchSize            int
+	factory              *JetStreamRequesterFactory
+	ackPolicy            string
+	msgID                uint64
+	received             []*nats.Msg
 }
 
 // Setup prepares the Requester for benchmarking.
 func (j *jetstreamRequester) Setup() error {
+	j.ackPolicy = j.factory.AckPolicy
+	if j.ackPolicy == "" {
+		j.ackPolicy = "explicit"
+	}
+	subOpts := []nats.SubOpt{nats.ManualAck()}
+	switch j.ackPolicy {
+	case "none":
+		subOpts = append(subOpts, nats.AckNone())
+	case "all":
+		subOpts = append(subOpts, nats.AckAll())
+	case "explicit":
+		subOpts = append(subOpts, nats.AckExplicit())
+	default:
+		return fmt.Errorf("requester: invalid JetStream AckPolicy %q", j.factory.AckPolicy)
+	}
+	if j.factory.Pull && j.ackPolicy != "explicit" {
+		return fmt.Errorf("requester: JetStream AckPolicy %q isn't supported by pull consumers", j.ackPolicy)
+	}
+	if j.factory.AckWait > 0 {
+		subOpts = append(subOpts, nats.AckWait(j.factory.AckWait))
+	}
+
 	conn, err := nats.Connect(j.url)
 	if err != nil {
 		return err
 	}
 
-	js, err := conn.JetStream(nats.PublishAsyncMaxPending(j.maxPublishAckPending))
+	var jsOpts []nats.JSOpt
+	if j.maxPublishAckPending > 0 {
+		jsOpts = append(jsOpts, nats.PublishAsyncMaxPending(j.maxPublishAckPending))
+	}
+	js, err := conn.JetStream(jsOpts...)
 	if err != nil {
 		conn.Close()
 		return err
 	}
 
-	_, err = js.AddStream(&nats.StreamConfig{Name: j.stream, Subjects: []string{j.subject}})
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:       j.stream,
+		Subjects:   []string{j.subject},
+		Storage:    j.factory.Storage,
+		Replicas:   j.factory.Replicas,
+		Retention:  j.factory.Retention,
+		MaxMsgs:    j.factory.MaxMsgs,
+		MaxBytes:   j.factory.MaxBytes,
+		Duplicates: j.factory.DuplicateWindow,
+	})
 	if err != nil {
 		conn.Close()
 		return err
 	}
 
-	j.inbound = make(chan nats.Msg)
-	sub, err := js.Subscribe(j.subject, func(m *nats.Msg) {
-		j.inbound <- *m
-		m.AckSync()
-	}, nats.Durable("bench_consumer"))
+	var sub *nats.Subscription
+	if j.factory.Pull {
+		sub, err = js.PullSubscribe(j.subject, "bench_consumer", subOpts...)
+	} else {
+		j.inbound = make(chan *nats.Msg)
+		sub, err = js.Subscribe(j.subject, func(m *nats.Msg) {
+			j.inbound <- m
+		}, append(subOpts, nats.Durable("bench_consumer"))...)
+	}
 	if err != nil {
 		j.inbound = nil
+		js.DeleteStream(j.stream)
 		conn.Close()
 		return err
 	}
@@ -87,24 +166,67 @@ func (j *jetstreamRequester) Setup() error {
 		msg[i] = 'A' + uint8(rand.Intn(26))
 	}
 	j.msg = msg
+	j.msgID = 0
+	j.received = make([]*nats.Msg, 0, j.batchSize)
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (j *jetstreamRequester) Request() error {
-	if j.asyncPublish {
-		if _, err := j.js.PublishAsync(j.subject, j.msg); err != nil {
+	for i := 0; i < j.batchSize; i++ {
+		if err := j.publish(); err != nil {
 			return err
 		}
+	}
+
+	j.received = j.received[:0]
+	if j.factory.Pull {
+		for len(j.received) < j.batchSize {
+			msgs, err := j.sub.Fetch(j.batchSize-len(j.received), nats.MaxWait(30*time.Second))
+			if err != nil {
+				return err
+			}
+			j.received = append(j.received, msgs...)
+		}
 	} else {
-		if _, err := j.js.Publish(j.subject, j.msg); err != nil {
-			return err
+		for len(j.received) < j.batchSize {
+			select {
+			case msg := <-j.inbound:
+				j.received = append(j.received, msg)
+			case <-time.After(30 * time.Second):
+				return errors.New("timeout")
+			}
 		}
 	}
-	select {
-	case <-j.inbound:
-	case <-time.After(30 * time.Second):
-		return errors.New("timeout")
+	return j.ack()
+}
+
+// publish publishes a message, with a unique message ID if deduplicating.
+func (j *jetstreamRequester) publish() error {
+	var opts []nats.PubOpt
+	if j.factory.DuplicateWindow > 0 {
+		j.msgID++
+		opts = append(opts, nats.MsgId(j.stream+"-"+strconv.FormatUint(j.msgID, 10)))
+	}
+	if j.asyncPublish {
+		_, err := j.js.PublishAsync(j.subject, j.msg, opts...)
+		return err
+	}
+	_, err := j.js.Publish(j.subject, j.msg, opts...)
+	return err
+}
+
+// ack acknowledges the received messages according to the ack policy.
+func (j *jetstreamRequester) ack() error {
+	switch j.ackPolicy {
+	case "all":
+		return j.received[len(j.received)-1].AckSync()
+	case "explicit":
+		for _, msg := range j.received {
+			if err := msg.AckSync(); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -125,6 +247,7 @@ func (j *jetstreamRequester) Teardown() error {
 		return err
 	}
 	j.sub = nil
+	j.inbound = nil
 	j.conn.Close()
 	j.conn = nil
 	return nil
@@ -0,0 +1,45 @@
+package requester_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestJetStreamRequester(t *testing.T) {
+	url := requestertest.NATS(t)
+	for _, test := range []struct {
+		name    string
+		factory *requester.JetStreamRequesterFactory
+	}{
+		{"push", &requester.JetStreamRequesterFactory{Stream: "push", AckPolicy: "all"}},
+		{"push async", &requester.JetStreamRequesterFactory{Stream: "pushasync", AsyncPublish: true, AckPolicy: "none"}},
+		{"pull", &requester.JetStreamRequesterFactory{Stream: "pull", Pull: true, BatchSize: 4}},
+		{"pull deduplicated", &requester.JetStreamRequesterFactory{Stream: "pulldedup", Pull: true, DuplicateWindow: time.Minute}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.factory.URL = url
+			test.factory.PayloadSize = 64
+			test.factory.Storage = nats.MemoryStorage
+			requestertest.Check(t, test.factory, 10)
+			requestertest.Benchmark(t, test.factory, 2, 200*time.Millisecond)
+		})
+	}
+}
+
+func TestJetStreamRequesterPullAckPolicy(t *testing.T) {
+	factory := &requester.JetStreamRequesterFactory{
+		URL:       requestertest.NATS(t),
+		Stream:    "pull",
+		Pull:      true,
+		AckPolicy: "all",
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err == nil {
+		r.Teardown()
+		t.Fatal("Setup succeeded for a pull consumer with AckPolicy all")
+	}
+}
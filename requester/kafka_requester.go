@@ -1,58 +1,292 @@
 package requester
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
-	"math/rand"
+	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/Shopify/sarama"
 	"github.com/ssd532/bench/v2"
 )
 
 // KafkaRequesterFactory implements RequesterFactory by creating a Requester
-// which publishes messages to Kafka and waits to consume them.
+// which publishes messages to Kafka and waits to consume them. The producer
+// settings used are reported in Summary.Metadata.
 type KafkaRequesterFactory struct {
 	URLs        []string
 	PayloadSize int
 	Topic       string
 	DoConsume   bool
 	IsAsync     bool
+
+	// Acks is the acknowledgement level required from brokers: "0" for
+	// none, "1" for the leader or "all" for all in-sync replicas. Defaults
+	// to "1", or "all" with Idempotent.
+	Acks string
+
+	// Idempotent enables the idempotent producer. It requires Acks "all"
+	// and MaxInFlight 1, which are the defaults when it's enabled.
+	Idempotent bool
+
+	// Compression is the codec used to compress message batches.
+	Compression sarama.CompressionCodec
+
+	// Linger is the maximum time messages are buffered before a batch is
+	// sent. FlushBytes and FlushMessages trigger sending a batch once it
+	// reaches the given size. Zero values mean the sarama defaults.
+	Linger        time.Duration
+	FlushBytes    int
+	FlushMessages int
+
+	// MaxInFlight is the number of unacknowledged requests per broker
+	// connection. Defaults to 5, or 1 with Idempotent.
+	MaxInFlight int
+
+	// Partitioner is one of "hash", "random", "roundrobin" or
+	// "referencehash". Defaults to "hash".
+	Partitioner string
+
+	// Keys, if set, chooses the key of every message.
+	Keys KeyGenerator
+
+	// KeyValue, if set, names the ChainValues entry used as the message key
+	// when the Requester is a chain step. If the entry is missing, the key
+	// chosen by Keys is added under the name, e.g. for a later step reading
+	// the message's state.
+	KeyValue string
+
+	// Headers are added to every message.
+	Headers map[string]string
+
+	// SASLMechanism enables SASL authentication with SASLUser and
+	// SASLPassword, e.g. sarama.SASLTypePlaintext. SCRAM mechanisms require
+	// SCRAMClientGenerator.
+	SASLMechanism        sarama.SASLMechanism
+	SASLUser             string
+	SASLPassword         string
+	SCRAMClientGenerator func() sarama.SCRAMClient
+
+	// TLSConfig, if set, enables TLS for broker connections.
+	TLSConfig *tls.Config
+
+	// Version is the Kafka protocol version, e.g. "2.8.0". Defaults to the
+	// sarama default.
+	Version string
+
+	// Partitions, if greater than zero, creates the topic in Setup with the
+	// given number of partitions and ReplicationFactor, which defaults to 1.
+	// Otherwise the topic is expected to exist or be created automatically.
+	// DeleteTopic deletes created topics in Teardown.
+	Partitions        int32
+	ReplicationFactor int16
+	DeleteTopic       bool
+
+	// ConsumerGroup, if set, consumes messages as a member of the named
+	// consumer group. Otherwise all partitions of the topic are consumed
+	// directly.
+	ConsumerGroup string
+
+	// Transactional produces messages in transactions of TransactionSize
+	// requests, which defaults to 1. The request completing a transaction
+	// commits it and, if consuming, waits to consume all of its messages
+	// with read_committed isolation. It implies Idempotent. The produce and
+	// commit latencies are reported as the "kafka.produce" and
+	// "kafka.commit" histograms. With IsAsync, every message is produced
+	// asynchronously but waits for its ack, which is included in the
+	// produce latency. TransactionTimeout defaults to the sarama default.
+	Transactional      bool
+	TransactionSize    int
+	TransactionTimeout time.Duration
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (k *KafkaRequesterFactory) GetRequester(num uint64) bench.Requester {
-	return &kafkaRequester{
+	requester := &kafkaRequester{
 		urls:        k.URLs,
 		payloadSize: k.PayloadSize,
 		topic:       k.Topic + "-" + strconv.FormatUint(num, 10),
 		doConsume:   k.DoConsume,
 		isAsync:     k.IsAsync,
+		factory:     k,
+	}
+	if k.ConsumerGroup != "" {
+		requester.group = k.ConsumerGroup + "-" + strconv.FormatUint(num, 10)
+	}
+	return requester
+}
+
+// newConfig returns the client configuration for the factory's settings.
+func (k *KafkaRequesterFactory) newConfig() (*sarama.Config, error) {
+	config := sarama.NewConfig()
+
+	if k.Version != "" {
+		version, err := sarama.ParseKafkaVersion(k.Version)
+		if err != nil {
+			return nil, err
+		}
+		config.Version = version
+	}
+
+	idempotent := k.Idempotent || k.Transactional
+	acks := k.Acks
+	if acks == "" && idempotent {
+		acks = "all"
+	}
+	switch acks {
+	case "":
+	case "0":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	case "1":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all", "-1":
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return nil, fmt.Errorf("requester: invalid Kafka Acks %q", k.Acks)
+	}
+
+	config.Producer.Idempotent = idempotent
+	if k.MaxInFlight > 0 {
+		config.Net.MaxOpenRequests = k.MaxInFlight
+	} else if idempotent {
+		config.Net.MaxOpenRequests = 1
+	}
+
+	if k.Transactional {
+		config.Consumer.IsolationLevel = sarama.ReadCommitted
+		if k.TransactionTimeout > 0 {
+			config.Producer.Transaction.Timeout = k.TransactionTimeout
+		}
+	}
+
+	config.Producer.Compression = k.Compression
+	if k.Linger > 0 {
+		config.Producer.Flush.Frequency = k.Linger
+	}
+	if k.FlushBytes > 0 {
+		config.Producer.Flush.Bytes = k.FlushBytes
+	}
+	if k.FlushMessages > 0 {
+		config.Producer.Flush.Messages = k.FlushMessages
+	}
+
+	switch k.Partitioner {
+	case "", "hash":
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case "random":
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	case "referencehash":
+		config.Producer.Partitioner = sarama.NewReferenceHashPartitioner
+	default:
+		return nil, fmt.Errorf("requester: invalid Kafka Partitioner %q", k.Partitioner)
+	}
+
+	if k.SASLMechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.Mechanism = k.SASLMechanism
+		config.Net.SASL.User = k.SASLUser
+		config.Net.SASL.Password = k.SASLPassword
+		config.Net.SASL.SCRAMClientGeneratorFunc = k.SCRAMClientGenerator
+	}
+	if k.TLSConfig != nil {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = k.TLSConfig
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// kafkaMetadata describes the producer settings of the configuration.
+func kafkaMetadata(config *sarama.Config, partitioner string) map[string]string {
+	if partitioner == "" {
+		partitioner = "hash"
+	}
+	sasl := "none"
+	if config.Net.SASL.Enable {
+		sasl = string(config.Net.SASL.Mechanism)
+	}
+	return map[string]string{
+		"kafka.version":        config.Version.String(),
+		"kafka.acks":           strconv.Itoa(int(config.Producer.RequiredAcks)),
+		"kafka.idempotent":     strconv.FormatBool(config.Producer.Idempotent),
+		"kafka.compression":    config.Producer.Compression.String(),
+		"kafka.linger":         config.Producer.Flush.Frequency.String(),
+		"kafka.flush_bytes":    strconv.Itoa(config.Producer.Flush.Bytes),
+		"kafka.flush_messages": strconv.Itoa(config.Producer.Flush.Messages),
+		"kafka.max_in_flight":  strconv.Itoa(config.Net.MaxOpenRequests),
+		"kafka.partitioner":    partitioner,
+		"kafka.sasl":           sasl,
+		"kafka.tls":            strconv.FormatBool(config.Net.TLS.Enable),
 	}
 }
 
 // kafkaRequester implements Requester by publishing a message to Kafka and
 // waiting to consume it.
 type kafkaRequester struct {
-	urls              []string
-	payloadSize       int
-	topic             string
-	asyncProducer     sarama.AsyncProducer
-	syncProducer      sarama.SyncProducer
-	consumer          sarama.Consumer
-	partitionConsumer sarama.PartitionConsumer
-	msg               *sarama.ProducerMessage
-	doConsume         bool
-	isAsync           bool
+	urls          []string
+	payloadSize   int
+	topic         string
+	group         string
+	asyncProducer sarama.AsyncProducer
+	syncProducer  sarama.SyncProducer
+	admin         sarama.ClusterAdmin
+	consumer      kafkaConsumer
+	payload       []byte
+	headers       []sarama.RecordHeader
+	metadata      map[string]string
+	doConsume     bool
+	isAsync       bool
+	factory       *KafkaRequesterFactory
+
+	// txn is the producer when producing in transactions, pending the number
+	// of messages produced in the current transaction.
+	txn              kafkaTxnProducer
+	transactionSize  int
+	pending          int
+	produceHistogram *hdrhistogram.Histogram
+	commitHistogram  *hdrhistogram.Histogram
+}
+
+// kafkaTxnProducer is implemented by both sarama producers.
+type kafkaTxnProducer interface {
+	BeginTxn() error
+	CommitTxn() error
+	AbortTxn() error
 }
 
 // Setup prepares the Requester for benchmarking.
 func (k *kafkaRequester) Setup() error {
-	config := sarama.NewConfig()
-	var err error
+	config, err := k.factory.newConfig()
+	if err != nil {
+		return err
+	}
+
+	if k.factory.Partitions > 0 {
+		if err := k.createTopic(config); err != nil {
+			return err
+		}
+	}
+
+	if k.factory.Transactional {
+		config.Producer.Transaction.ID = "bench-" + k.topic
+	}
+
 	var asyncProducer sarama.AsyncProducer
 	var syncProducer sarama.SyncProducer
 	if k.isAsync {
+		if k.factory.Transactional {
+			// Transactional requests wait for the ack of every message.
+			config.Producer.Return.Successes = true
+		}
 		asyncProducer, err = sarama.NewAsyncProducer(k.urls, config)
 	} else {
 		config.Producer.Return.Successes = true
@@ -60,81 +294,250 @@ func (k *kafkaRequester) Setup() error {
 	}
 
 	if err != nil {
+		k.closeAdmin()
 		return err
 	}
 
-	var consumer sarama.Consumer
-	var partitionConsumer sarama.PartitionConsumer
+	var consumer kafkaConsumer
 	if k.doConsume {
-		consumer, err = sarama.NewConsumer(k.urls, nil)
-		if err != nil {
-			if k.isAsync {
-				asyncProducer.Close()
-			} else {
-				syncProducer.Close()
-			}
-			return err
+		if k.group != "" {
+			consumer, err = newKafkaGroupConsumer(k.urls, config, k.group, k.topic)
+		} else {
+			consumer, err = newKafkaPartitionsConsumer(k.urls, config, k.topic)
 		}
-
-		partitionConsumer, err = consumer.ConsumePartition(k.topic, 0, sarama.OffsetNewest)
 		if err != nil {
 			if k.isAsync {
 				asyncProducer.Close()
 			} else {
 				syncProducer.Close()
 			}
-			consumer.Close()
+			k.closeAdmin()
 			return err
 		}
 	}
 
 	if k.isAsync {
 		k.asyncProducer = asyncProducer
+		k.txn = asyncProducer
 	} else {
 		k.syncProducer = syncProducer
+		k.txn = syncProducer
+	}
+	if !k.factory.Transactional {
+		k.txn = nil
+	}
+	k.transactionSize = k.factory.TransactionSize
+	if k.transactionSize < 1 {
+		k.transactionSize = 1
 	}
+	k.pending = 0
+	k.produceHistogram = bench.NewHistogram()
+	k.commitHistogram = bench.NewHistogram()
 	k.consumer = consumer
-	k.partitionConsumer = partitionConsumer
-	msg := make([]byte, k.payloadSize)
-	for i := 0; i < k.payloadSize; i++ {
-		msg[i] = 'A' + uint8(rand.Intn(26))
+	k.payload = randomPayload(k.payloadSize)
+	k.headers = k.headers[:0]
+	for key, value := range k.factory.Headers {
+		k.headers = append(k.headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
 	}
-	k.msg = &sarama.ProducerMessage{
-		Topic: k.topic,
-		Value: sarama.ByteEncoder(msg),
+	k.metadata = kafkaMetadata(config, k.factory.Partitioner)
+	k.metadata["kafka.partitions"] = strconv.Itoa(int(k.factory.Partitions))
+	k.metadata["kafka.replication_factor"] = strconv.Itoa(int(k.replicationFactor()))
+	k.metadata["kafka.consumer_group"] = strconv.FormatBool(k.group != "")
+	k.metadata["kafka.transactional"] = strconv.FormatBool(k.factory.Transactional)
+	if k.factory.Transactional {
+		k.metadata["kafka.transaction_size"] = strconv.Itoa(k.transactionSize)
+		k.metadata["kafka.isolation_level"] = "read_committed"
 	}
 
 	return nil
 }
 
+// replicationFactor returns the configured replication factor, defaulting
+// to 1.
+func (k *kafkaRequester) replicationFactor() int16 {
+	if k.factory.ReplicationFactor == 0 {
+		return 1
+	}
+	return k.factory.ReplicationFactor
+}
+
+// createTopic creates the topic with the configured number of partitions and
+// replication factor, unless it already exists.
+func (k *kafkaRequester) createTopic(config *sarama.Config) error {
+	admin, err := sarama.NewClusterAdmin(k.urls, config)
+	if err != nil {
+		return err
+	}
+	err = admin.CreateTopic(k.topic, &sarama.TopicDetail{
+		NumPartitions:     k.factory.Partitions,
+		ReplicationFactor: k.replicationFactor(),
+	}, false)
+	if topicErr, ok := err.(*sarama.TopicError); ok && topicErr.Err == sarama.ErrTopicAlreadyExists {
+		err = nil
+	}
+	if err != nil {
+		admin.Close()
+		return err
+	}
+	k.admin = admin
+	return nil
+}
+
+// closeAdmin closes the cluster admin, if any.
+func (k *kafkaRequester) closeAdmin() error {
+	if k.admin == nil {
+		return nil
+	}
+	err := k.admin.Close()
+	k.admin = nil
+	return err
+}
+
+// newMessage returns the message to publish for the next request, reading or
+// adding its key in the values of a chain request.
+func (k *kafkaRequester) newMessage(values ChainValues) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic:   k.topic,
+		Value:   sarama.ByteEncoder(k.payload),
+		Headers: k.headers,
+	}
+	if key, ok := values[k.factory.KeyValue]; ok && k.factory.KeyValue != "" {
+		msg.Key = sarama.StringEncoder(fmt.Sprint(key))
+	} else if k.factory.Keys != nil {
+		key := strconv.FormatUint(k.factory.Keys(), 10)
+		msg.Key = sarama.StringEncoder(key)
+		if values != nil && k.factory.KeyValue != "" {
+			values[k.factory.KeyValue] = key
+		}
+	}
+	return msg
+}
+
+// Report returns the producer settings used and, when producing in
+// transactions, the produce and commit latency histograms.
+func (k *kafkaRequester) Report() *bench.Report {
+	report := &bench.Report{Metadata: k.metadata}
+	if k.txn != nil {
+		report.Histograms = map[string]*hdrhistogram.Histogram{
+			"kafka.produce": k.produceHistogram,
+			"kafka.commit":  k.commitHistogram,
+		}
+	}
+	return report
+}
+
 // Request performs a synchronous request to the system under test.
 func (k *kafkaRequester) Request() error {
+	return k.request(k.newMessage(nil))
+}
+
+// ChainRequest performs a synchronous request to the system under test with
+// the message key read from or added to the values.
+func (k *kafkaRequester) ChainRequest(values ChainValues) error {
+	return k.request(k.newMessage(values))
+}
+
+// request publishes the message and, if consuming, waits to consume it.
+func (k *kafkaRequester) request(msg *sarama.ProducerMessage) error {
+	if k.txn != nil {
+		return k.transactionalRequest(msg)
+	}
+
 	if k.isAsync {
-		k.asyncProducer.Input() <- k.msg
+		k.asyncProducer.Input() <- msg
 	} else {
-		_, _, err := k.syncProducer.SendMessage(k.msg)
-		if err != nil {
-			panic("Error sending message: " + err.Error())
+		if _, _, err := k.syncProducer.SendMessage(msg); err != nil {
+			return err
 		}
 	}
 
 	if k.doConsume {
-		select {
-		case <-k.partitionConsumer.Messages():
-			return nil
-		case <-time.After(30 * time.Second):
-			return errors.New("requester: Request timed out receiving")
+		return k.receive()
+	}
+	return nil
+}
+
+// transactionalRequest produces the message in the current transaction,
+// beginning a new one if necessary, and commits it once it contains
+// transactionSize messages.
+func (k *kafkaRequester) transactionalRequest(msg *sarama.ProducerMessage) error {
+	if k.pending == 0 {
+		if err := k.txn.BeginTxn(); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	if err := k.produce(msg); err != nil {
+		k.abort()
+		return err
+	}
+	k.produceHistogram.RecordValue(time.Since(start).Nanoseconds())
+	k.pending++
+	if k.pending < k.transactionSize {
+		return nil
+	}
+
+	pending := k.pending
+	k.pending = 0
+	start = time.Now()
+	if err := k.txn.CommitTxn(); err != nil {
+		k.abort()
+		return err
+	}
+	k.commitHistogram.RecordValue(time.Since(start).Nanoseconds())
+
+	if k.doConsume {
+		for i := 0; i < pending; i++ {
+			if err := k.receive(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// produce produces the message in the current transaction and waits for its
+// ack.
+func (k *kafkaRequester) produce(msg *sarama.ProducerMessage) error {
+	if !k.isAsync {
+		_, _, err := k.syncProducer.SendMessage(msg)
+		return err
+	}
+	k.asyncProducer.Input() <- msg
+	select {
+	case <-k.asyncProducer.Successes():
+		return nil
+	case err := <-k.asyncProducer.Errors():
+		return err
+	}
+}
+
+// abort aborts the current transaction.
+func (k *kafkaRequester) abort() error {
+	k.pending = 0
+	return k.txn.AbortTxn()
+}
+
+// receive waits to consume a message.
+func (k *kafkaRequester) receive() error {
+	select {
+	case <-k.consumer.Messages():
+		return nil
+	case <-time.After(30 * time.Second):
+		return errors.New("requester: Request timed out receiving")
+	}
+}
+
 // Teardown is called upon benchmark completion.
 func (k *kafkaRequester) Teardown() error {
-	if k.doConsume {
-		if err := k.partitionConsumer.Close(); err != nil {
+	if k.txn != nil && k.pending > 0 {
+		k.pending = 0
+		if err := k.txn.CommitTxn(); err != nil {
 			return err
 		}
+	}
+	if k.doConsume {
 		if err := k.consumer.Close(); err != nil {
 			return err
 		}
@@ -148,9 +551,180 @@ func (k *kafkaRequester) Teardown() error {
 			return err
 		}
 	}
-	k.partitionConsumer = nil
+	if k.admin != nil && k.factory.DeleteTopic {
+		if err := k.admin.DeleteTopic(k.topic); err != nil {
+			return err
+		}
+	}
+	if err := k.closeAdmin(); err != nil {
+		return err
+	}
 	k.consumer = nil
 	k.asyncProducer = nil
 	k.syncProducer = nil
+	k.txn = nil
 	return nil
 }
+
+// kafkaConsumer consumes the messages of a topic.
+type kafkaConsumer interface {
+	// Messages returns the channel of consumed messages.
+	Messages() <-chan *sarama.ConsumerMessage
+
+	// Close stops consuming.
+	Close() error
+}
+
+// kafkaPartitionsConsumer consumes new messages from all partitions of a
+// topic.
+type kafkaPartitionsConsumer struct {
+	consumer   sarama.Consumer
+	partitions []sarama.PartitionConsumer
+	messages   chan *sarama.ConsumerMessage
+	wg         sync.WaitGroup
+}
+
+// newKafkaPartitionsConsumer starts consuming new messages from all
+// partitions of the topic.
+func newKafkaPartitionsConsumer(urls []string, config *sarama.Config, topic string) (*kafkaPartitionsConsumer, error) {
+	consumer, err := sarama.NewConsumer(urls, config)
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := consumer.Partitions(topic)
+	if err != nil {
+		consumer.Close()
+		return nil, err
+	}
+	k := &kafkaPartitionsConsumer{
+		consumer: consumer,
+		messages: make(chan *sarama.ConsumerMessage),
+	}
+	for _, partition := range partitions {
+		partitionConsumer, err := consumer.ConsumePartition(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		k.partitions = append(k.partitions, partitionConsumer)
+		k.wg.Add(1)
+		go func() {
+			defer k.wg.Done()
+			for msg := range partitionConsumer.Messages() {
+				k.messages <- msg
+			}
+		}()
+	}
+	return k, nil
+}
+
+// Messages returns the channel of consumed messages.
+func (k *kafkaPartitionsConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return k.messages
+}
+
+// Close stops consuming.
+func (k *kafkaPartitionsConsumer) Close() error {
+	var firstErr error
+	for _, partitionConsumer := range k.partitions {
+		partitionConsumer.AsyncClose()
+	}
+	// Unblock forwarders of messages which are no longer received.
+	go func() {
+		for range k.messages {
+		}
+	}()
+	k.wg.Wait()
+	close(k.messages)
+	if err := k.consumer.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// kafkaGroupConsumer consumes new messages of a topic as a member of a
+// consumer group.
+type kafkaGroupConsumer struct {
+	group    sarama.ConsumerGroup
+	cancel   context.CancelFunc
+	done     chan struct{}
+	ready    chan struct{}
+	once     sync.Once
+	messages chan *sarama.ConsumerMessage
+}
+
+// newKafkaGroupConsumer joins the consumer group and waits until partitions
+// of the topic have been assigned.
+func newKafkaGroupConsumer(urls []string, config *sarama.Config, groupID, topic string) (*kafkaGroupConsumer, error) {
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	group, err := sarama.NewConsumerGroup(urls, groupID, config)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k := &kafkaGroupConsumer{
+		group:    group,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		ready:    make(chan struct{}),
+		messages: make(chan *sarama.ConsumerMessage),
+	}
+	errs := make(chan error, 1)
+	go func() {
+		defer close(k.done)
+		// Consume returns on rebalances, so rejoin until stopped.
+		for ctx.Err() == nil {
+			if err := group.Consume(ctx, []string{topic}, k); err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-k.ready:
+		return k, nil
+	case err := <-errs:
+		k.Close()
+		return nil, err
+	case <-time.After(30 * time.Second):
+		k.Close()
+		return nil, errors.New("requester: timed out joining consumer group")
+	}
+}
+
+// Setup is called when a new consumer group session starts.
+func (k *kafkaGroupConsumer) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup is called when a consumer group session ends.
+func (k *kafkaGroupConsumer) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim forwards the messages of a claimed partition.
+func (k *kafkaGroupConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	k.once.Do(func() { close(k.ready) })
+	for msg := range claim.Messages() {
+		select {
+		case k.messages <- msg:
+			session.MarkMessage(msg, "")
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// Messages returns the channel of consumed messages.
+func (k *kafkaGroupConsumer) Messages() <-chan *sarama.ConsumerMessage {
+	return k.messages
+}
+
+// Close leaves the consumer group.
+func (k *kafkaGroupConsumer) Close() error {
+	k.cancel()
+	err := k.group.Close()
+	<-k.done
+	return err
+}
@@ -20,6 +20,29 @@ type LiftbridgeRequesterFactory struct {
 	PayloadSize  int
 	Stream       string
 	AsyncPublish bool
+
+	// Partitions and ReplicationFactor configure the created stream. Both
+	// default to 1. All partitions are consumed.
+	Partitions        int32
+	ReplicationFactor int32
+
+	// Partitioner is "roundrobin" or "key", which partitions messages by
+	// their key. Defaults to "roundrobin".
+	Partitioner string
+
+	// AckPolicy is "leader", "all" or "none". Defaults to "all". With
+	// "none", asynchronously published messages aren't awaiting an ack.
+	AckPolicy string
+
+	// StartPosition is where subscriptions start: "earliest", "latest",
+	// "new" or "time", which starts at StartTime. Defaults to "earliest".
+	StartPosition string
+	StartTime     time.Time
+
+	// Keys, if set, chooses the key of every message. Headers are added to
+	// every message.
+	Keys    KeyGenerator
+	Headers map[string]string
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
@@ -30,6 +53,55 @@ func (l *LiftbridgeRequesterFactory) GetRequester(num uint64) bench.Requester {
 		subject:      l.Stream + "-" + strconv.FormatUint(num, 10),
 		stream:       l.Stream + "-" + strconv.FormatUint(num, 10) + "-stream",
 		asyncPublish: l.AsyncPublish,
+		factory:      l,
+	}
+}
+
+// messageOptions returns the options of published messages, except for the
+// key.
+func (l *LiftbridgeRequesterFactory) messageOptions() ([]lift.MessageOption, error) {
+	var opts []lift.MessageOption
+	switch l.AckPolicy {
+	case "", "all":
+		opts = append(opts, lift.AckPolicyAll())
+	case "leader":
+		opts = append(opts, lift.AckPolicyLeader())
+	case "none":
+		opts = append(opts, lift.AckPolicyNone())
+	default:
+		return nil, fmt.Errorf("requester: invalid Liftbridge AckPolicy %q", l.AckPolicy)
+	}
+	switch l.Partitioner {
+	case "", "roundrobin":
+		opts = append(opts, lift.PartitionByRoundRobin())
+	case "key":
+		opts = append(opts, lift.PartitionByKey())
+	default:
+		return nil, fmt.Errorf("requester: invalid Liftbridge Partitioner %q", l.Partitioner)
+	}
+	if len(l.Headers) > 0 {
+		headers := make(map[string][]byte, len(l.Headers))
+		for name, value := range l.Headers {
+			headers[name] = []byte(value)
+		}
+		opts = append(opts, lift.Headers(headers))
+	}
+	return opts, nil
+}
+
+// startPosition returns the subscription option for the start position.
+func (l *LiftbridgeRequesterFactory) startPosition() (lift.SubscriptionOption, error) {
+	switch l.StartPosition {
+	case "", "earliest":
+		return lift.StartAtEarliestReceived(), nil
+	case "latest":
+		return lift.StartAtLatestReceived(), nil
+	case "new":
+		return nil, nil
+	case "time":
+		return lift.StartAtTime(l.StartTime), nil
+	default:
+		return nil, fmt.Errorf("requester: invalid Liftbridge StartPosition %q", l.StartPosition)
 	}
 }
 
@@ -43,64 +115,119 @@ type liftbridgeRequester struct {
 	client       lift.Client
 	inbound      chan lift.Message
 	errch        chan error
+	cancel       context.CancelFunc
 	msg          []byte
+	msgOpts      []lift.MessageOption
 	asyncPublish bool
 	acksLeft     int64
+	factory      *LiftbridgeRequesterFactory
 }
 
 // Setup prepares the Requester for benchmarking.
 func (l *liftbridgeRequester) Setup() error {
+	msgOpts, err := l.factory.messageOptions()
+	if err != nil {
+		return err
+	}
+	start, err := l.factory.startPosition()
+	if err != nil {
+		return err
+	}
+	partitions := l.factory.Partitions
+	if partitions < 1 {
+		partitions = 1
+	}
+	replicationFactor := l.factory.ReplicationFactor
+	if replicationFactor < 1 {
+		replicationFactor = 1
+	}
+
 	client, err := lift.Connect(l.urls)
 	if err != nil {
 		return err
 	}
-	if err := client.CreateStream(context.Background(), l.subject, l.stream); err != nil {
+	if err := client.CreateStream(context.Background(), l.subject, l.stream,
+		lift.Partitions(partitions), lift.ReplicationFactor(replicationFactor)); err != nil {
 		if err != lift.ErrStreamExists {
+			client.Close()
 			return err
 		}
 	}
 
 	l.inbound = make(chan lift.Message)
-	l.errch = make(chan error)
+	l.errch = make(chan error, 1)
 	handleMessages := func(msg *lift.Message, err error) {
 		if err != nil {
-			l.errch <- err
+			l.reportError(err)
+			return
 		}
 		l.inbound <- *msg
 	}
 
-	if err := client.Subscribe(context.Background(), l.stream, handleMessages, lift.StartAtEarliestReceived()); err != nil {
-		l.inbound = nil
-		l.errch = nil
-		return err
+	ctx, cancel := context.WithCancel(context.Background())
+	for partition := int32(0); partition < partitions; partition++ {
+		opts := []lift.SubscriptionOption{lift.Partition(partition)}
+		if start != nil {
+			opts = append(opts, start)
+		}
+		if err := client.Subscribe(ctx, l.stream, handleMessages, opts...); err != nil {
+			cancel()
+			client.Close()
+			l.inbound = nil
+			l.errch = nil
+			return err
+		}
 	}
 
 	l.client = client
+	l.cancel = cancel
+	l.msgOpts = msgOpts
 	msg := make([]byte, l.payloadSize)
 	for i := 0; i < l.payloadSize; i++ {
 		msg[i] = 'A' + uint8(rand.Intn(26))
 	}
 	l.msg = msg
-	return err
+	return nil
+}
+
+// reportError reports an asynchronous error as the result of the current
+// request. Errors are dropped if one is already pending.
+func (l *liftbridgeRequester) reportError(err error) {
+	select {
+	case l.errch <- err:
+	default:
+	}
 }
 
 // Request performs a synchronous request to the system under test.
 func (l *liftbridgeRequester) Request() error {
+	opts := l.msgOpts
+	if l.factory.Keys != nil {
+		opts = append(opts[:len(opts):len(opts)], lift.Key([]byte(strconv.FormatUint(l.factory.Keys(), 10))))
+	}
 	if l.asyncPublish {
-		// For counting acks left to recieve
-		atomic.AddInt64(&l.acksLeft, 1)
-		if err := l.client.PublishAsync(context.Background(), l.stream, l.msg,
-			func(ack *lift.Ack, err error) {
+		// No ack is sent with AckPolicy "none", so a handler would only be
+		// called when the ack times out.
+		var handler lift.AckHandler
+		if l.factory.AckPolicy != "none" {
+			// For counting acks left to recieve
+			atomic.AddInt64(&l.acksLeft, 1)
+			handler = func(ack *lift.Ack, err error) {
 				if err != nil {
-					l.errch <- err
+					l.reportError(err)
 				}
 				// For counting acks left to recieve
 				atomic.AddInt64(&l.acksLeft, -1)
-			}, lift.AckPolicyAll()); err != nil {
+			}
+		}
+		if err := l.client.PublishAsync(context.Background(), l.stream, l.msg, handler, opts...); err != nil {
+			if handler != nil {
+				atomic.AddInt64(&l.acksLeft, -1)
+			}
 			return err
 		}
 	} else {
-		if _, err := l.client.Publish(context.Background(), l.stream, l.msg, lift.AckPolicyAll()); err != nil {
+		if _, err := l.client.Publish(context.Background(), l.stream, l.msg, opts...); err != nil {
 			return err
 		}
 	}
@@ -117,13 +244,12 @@ func (l *liftbridgeRequester) Request() error {
 // Teardown is called upon benchmark completion.
 func (l *liftbridgeRequester) Teardown() error {
 	if l.asyncPublish {
-		fmt.Printf("Called teardown, yet to recieve %v acks\n", atomic.LoadInt64(&l.acksLeft))
 		// Wait to recieve acks
 		for atomic.LoadInt64(&l.acksLeft) > 0 {
-			fmt.Println("Waiting 5s to recieve acks, left " + fmt.Sprint(atomic.LoadInt64(&l.acksLeft)))
-			<-time.After(5 * time.Second)
+			<-time.After(10 * time.Millisecond)
 		}
 	}
+	l.cancel()
 	err := l.client.Close()
 	if err != nil {
 		return err
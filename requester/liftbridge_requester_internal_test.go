@@ -0,0 +1,66 @@
+package requester
+
+import (
+	"context"
+	"testing"
+
+	lift "github.com/liftbridge-io/go-liftbridge/v2"
+)
+
+// fakeLiftbridgeClient is a lift.Client delivering every published message
+// to a liftbridgeRequester and recording the ack handlers passed.
+type fakeLiftbridgeClient struct {
+	lift.Client
+	requester *liftbridgeRequester
+	handlers  []lift.AckHandler
+}
+
+func (f *fakeLiftbridgeClient) PublishAsync(_ context.Context, _ string, _ []byte,
+	handler lift.AckHandler, _ ...lift.MessageOption) error {
+
+	f.handlers = append(f.handlers, handler)
+	go func() { f.requester.inbound <- lift.Message{} }()
+	return nil
+}
+
+func (f *fakeLiftbridgeClient) Close() error {
+	return nil
+}
+
+func TestLiftbridgeRequesterAsyncAckPolicy(t *testing.T) {
+	for _, test := range []struct {
+		ackPolicy   string
+		wantHandler bool
+	}{
+		{"all", true},
+		{"leader", true},
+		{"none", false},
+	} {
+		t.Run(test.ackPolicy, func(t *testing.T) {
+			factory := &LiftbridgeRequesterFactory{Stream: "bench", AsyncPublish: true, AckPolicy: test.ackPolicy}
+			l := factory.GetRequester(0).(*liftbridgeRequester)
+			client := &fakeLiftbridgeClient{requester: l}
+			_, cancel := context.WithCancel(context.Background())
+			l.client, l.cancel = client, cancel
+			l.inbound = make(chan lift.Message)
+			l.errch = make(chan error, 1)
+
+			for i := 0; i < 3; i++ {
+				if err := l.Request(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, handler := range client.handlers {
+				if (handler != nil) != test.wantHandler {
+					t.Fatalf("publish %d: got handler %v, want %v", i, handler != nil, test.wantHandler)
+				}
+				if handler != nil {
+					handler(&lift.Ack{}, nil)
+				}
+			}
+			if err := l.Teardown(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
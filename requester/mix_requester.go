@@ -0,0 +1,161 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ssd532/bench/v2"
+)
+
+// MixMember is one kind of request in the workload of a MixRequesterFactory.
+type MixMember struct {
+	// Name labels the member's requests and prefixes the results it
+	// reports. It must be unique within the mix.
+	Name string
+
+	// Factory creates the member's Requesters.
+	Factory bench.RequesterFactory
+
+	// Weight is the relative frequency of the member's requests in the mix.
+	// A zero weight is treated as 1.
+	Weight uint64
+}
+
+// MixRequesterFactory implements RequesterFactory by creating a Requester
+// which issues a weighted mix of requests of other Requesters, e.g. 70% Redis
+// GETs, 20% HTTP calls and 10% Kafka publishes, at the Benchmark's aggregate
+// rate. Each Benchmark connection gets a Requester of every member and
+// chooses one of them at random for every request.
+//
+// The Summary contains the results of the whole mix, the results of every
+// member in Labels under its name, and the histograms, counters and metadata
+// reported by members implementing Reporter, prefixed with the member's name
+// and a dot, e.g. "web.http.dns". Labels of members implementing Labeler
+// aren't recorded.
+type MixRequesterFactory struct {
+	Members []MixMember
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (m *MixRequesterFactory) GetRequester(num uint64) bench.Requester {
+	requesters := make([]bench.Requester, len(m.Members))
+	weights := make([]uint64, len(m.Members))
+	for i, member := range m.Members {
+		if member.Factory != nil {
+			requesters[i] = member.Factory.GetRequester(num)
+		}
+		weights[i] = member.Weight
+	}
+	return &mixRequester{
+		members:    m.Members,
+		requesters: requesters,
+		choice:     newWeightedChoice(weights),
+		factory:    m,
+	}
+}
+
+// validate returns an error if the members are misconfigured.
+func (m *MixRequesterFactory) validate() error {
+	if len(m.Members) == 0 {
+		return errors.New("no mix members configured")
+	}
+	names := make(map[string]bool, len(m.Members))
+	for _, member := range m.Members {
+		if member.Name == "" {
+			return errors.New("mix member without a name")
+		}
+		if member.Factory == nil {
+			return fmt.Errorf("mix member %q has no Factory", member.Name)
+		}
+		if names[member.Name] {
+			return fmt.Errorf("duplicate mix member %q", member.Name)
+		}
+		names[member.Name] = true
+	}
+	return nil
+}
+
+// mixRequester implements Requester by issuing the request of a member chosen
+// at random.
+type mixRequester struct {
+	members    []MixMember
+	requesters []bench.Requester
+	choice     *weightedChoice
+	factory    *MixRequesterFactory
+	last       int
+}
+
+// Setup prepares the Requester for benchmarking.
+func (m *mixRequester) Setup() error {
+	if err := m.factory.validate(); err != nil {
+		return fmt.Errorf("requester: %v", err)
+	}
+	for i, requester := range m.requesters {
+		if err := requester.Setup(); err != nil {
+			for _, r := range m.requesters[:i] {
+				r.Teardown()
+			}
+			return fmt.Errorf("requester: mix member %q: %v", m.members[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (m *mixRequester) Request() error {
+	m.last = m.choice.choose()
+	return m.requesters[m.last].Request()
+}
+
+// Label returns the name of the member which issued the last request.
+func (m *mixRequester) Label() string {
+	return m.members[m.last].Name
+}
+
+// Report returns the results reported by the members, prefixed with their
+// names.
+func (m *mixRequester) Report() *bench.Report {
+	report := &bench.Report{
+		Histograms: make(map[string]*hdrhistogram.Histogram),
+		Counters:   make(map[string]uint64),
+		Metadata:   make(map[string]string),
+	}
+	for i, requester := range m.requesters {
+		addPrefixedReport(report, m.members[i].Name+".", requester)
+	}
+	return report
+}
+
+// addPrefixedReport adds the results reported by the requester, if it
+// implements Reporter, to the report with their names prefixed.
+func addPrefixedReport(report *bench.Report, prefix string, requester bench.Requester) {
+	reporter, ok := requester.(bench.Reporter)
+	if !ok {
+		return
+	}
+	r := reporter.Report()
+	if r == nil {
+		return
+	}
+	for name, histogram := range r.Histograms {
+		report.Histograms[prefix+name] = histogram
+	}
+	for name, count := range r.Counters {
+		report.Counters[prefix+name] = count
+	}
+	for name, value := range r.Metadata {
+		report.Metadata[prefix+name] = value
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (m *mixRequester) Teardown() error {
+	var firstErr error
+	for i, requester := range m.requesters {
+		if err := requester.Teardown(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("requester: mix member %q: %v", m.members[i].Name, err)
+		}
+	}
+	return firstErr
+}
@@ -1,71 +1,333 @@
 package requester
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/ssd532/bench/v2"
 )
 
+// NATSMode determines how the NATS requester exchanges messages.
+type NATSMode int
+
+const (
+	// NATSPublishSubscribe publishes a message and waits to receive it on a
+	// subscription.
+	NATSPublishSubscribe NATSMode = iota
+
+	// NATSRequestReply sends a request and waits for the reply of a
+	// responder.
+	NATSRequestReply
+
+	// NATSQueueGroup publishes a message and waits for it to be received by
+	// one member of a queue group.
+	NATSQueueGroup
+)
+
+// String returns the name of the mode.
+func (m NATSMode) String() string {
+	switch m {
+	case NATSPublishSubscribe:
+		return "pubsub"
+	case NATSRequestReply:
+		return "request"
+	case NATSQueueGroup:
+		return "queue"
+	default:
+		return "unknown"
+	}
+}
+
 // NATSRequesterFactory implements RequesterFactory by creating a Requester
 // which publishes messages to NATS and waits to receive them.
 type NATSRequesterFactory struct {
 	URL         string
 	PayloadSize int
 	Subject     string
+
+	// Mode defaults to NATSPublishSubscribe. In NATSRequestReply mode,
+	// requests are sent to Subject, which is shared by all Benchmark
+	// connections, rather than to a subject per connection.
+	Mode NATSMode
+
+	// Responders, if greater than zero, starts a pool of in-process
+	// responders in NATSRequestReply mode, which echo requests. Each
+	// responder has its own connection and they form a queue group.
+	// Otherwise an external responder is expected.
+	Responders int
+
+	// QueueGroupSize is the number of queue group members in NATSQueueGroup
+	// mode, each with its own connection. Defaults to 2.
+	QueueGroupSize int
+
+	// SubjectValue, if set, names the ChainValues entry appended to the
+	// subject as a final token when the Requester is a chain step, e.g. to
+	// send a request about an order to "orders.<id>". Subscriptions and
+	// in-process responders then use a wildcard for the token, and requests
+	// fail if the entry is missing, including outside of chains.
+	SubjectValue string
+
+	// Timeout limits the time spent waiting for a message or reply. Defaults
+	// to 30 seconds.
+	Timeout time.Duration
+
+	// TLSConfig, if set, enables TLS. CredentialsFile is a user credentials
+	// file, User and Password are used for user authentication and Token
+	// for token authentication.
+	TLSConfig       *tls.Config
+	CredentialsFile string
+	User            string
+	Password        string
+	Token           string
+
+	// FlusherTimeout, PingInterval and MaxPingsOutstanding configure the
+	// connection. Zero values mean the nats.go defaults.
+	FlusherTimeout      time.Duration
+	PingInterval        time.Duration
+	MaxPingsOutstanding int
+
+	mu         sync.Mutex
+	responders []*nats.Conn
+	users      int
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (n *NATSRequesterFactory) GetRequester(num uint64) bench.Requester {
+	subject := n.Subject + "-" + strconv.FormatUint(num, 10)
+	if n.Mode == NATSRequestReply {
+		subject = n.Subject
+	}
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
 	return &natsRequester{
 		url:         n.URL,
 		payloadSize: n.PayloadSize,
-		subject:     n.Subject + "-" + strconv.FormatUint(num, 10),
+		subject:     subject,
+		timeout:     timeout,
+		factory:     n,
+	}
+}
+
+// options returns the connection options.
+func (n *NATSRequesterFactory) options() []nats.Option {
+	var opts []nats.Option
+	if n.TLSConfig != nil {
+		opts = append(opts, nats.Secure(n.TLSConfig))
+	}
+	if n.CredentialsFile != "" {
+		opts = append(opts, nats.UserCredentials(n.CredentialsFile))
+	}
+	if n.User != "" {
+		opts = append(opts, nats.UserInfo(n.User, n.Password))
+	}
+	if n.Token != "" {
+		opts = append(opts, nats.Token(n.Token))
+	}
+	if n.FlusherTimeout > 0 {
+		opts = append(opts, nats.FlusherTimeout(n.FlusherTimeout))
+	}
+	if n.PingInterval > 0 {
+		opts = append(opts, nats.PingInterval(n.PingInterval))
+	}
+	if n.MaxPingsOutstanding > 0 {
+		opts = append(opts, nats.MaxPingsOutstanding(n.MaxPingsOutstanding))
+	}
+	return opts
+}
+
+// acquireResponders starts the responder pool if necessary.
+func (n *NATSRequesterFactory) acquireResponders() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.users == 0 {
+		for i := 0; i < n.Responders; i++ {
+			conn, err := nats.Connect(n.URL, n.options()...)
+			if err != nil {
+				n.closeResponders()
+				return err
+			}
+			n.responders = append(n.responders, conn)
+			_, err = conn.QueueSubscribe(n.listenSubject(n.Subject), "bench-responders", func(msg *nats.Msg) {
+				msg.Respond(msg.Data)
+			})
+			if err == nil {
+				err = conn.Flush()
+			}
+			if err != nil {
+				n.closeResponders()
+				return err
+			}
+		}
+	}
+	n.users++
+	return nil
+}
+
+// listenSubject returns the subject to subscribe to for requests sent to the
+// given subject.
+func (n *NATSRequesterFactory) listenSubject(subject string) string {
+	if n.SubjectValue != "" {
+		return subject + ".*"
+	}
+	return subject
+}
+
+// releaseResponders stops the responder pool when no Benchmark connections
+// are using it anymore.
+func (n *NATSRequesterFactory) releaseResponders() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.users--
+	if n.users == 0 {
+		n.closeResponders()
 	}
 }
 
+// closeResponders closes the connections of the responder pool.
+func (n *NATSRequesterFactory) closeResponders() {
+	for _, conn := range n.responders {
+		conn.Close()
+	}
+	n.responders = nil
+}
+
 // natsRequester implements Requester by publishing a message to NATS and
 // waiting to receive it.
 type natsRequester struct {
 	url         string
 	payloadSize int
 	subject     string
+	timeout     time.Duration
+	factory     *NATSRequesterFactory
 	conn        *nats.Conn
 	sub         *nats.Subscription
+	members     []*nats.Conn
+	inbound     chan *nats.Msg
+	responders  bool
 	msg         []byte
 }
 
 // Setup prepares the Requester for benchmarking.
 func (n *natsRequester) Setup() error {
-	conn, err := nats.Connect(n.url)
+	conn, err := nats.Connect(n.url, n.factory.options()...)
 	if err != nil {
 		return err
 	}
-	sub, err := conn.SubscribeSync(n.subject)
+	switch n.factory.Mode {
+	case NATSPublishSubscribe:
+		n.sub, err = conn.SubscribeSync(n.factory.listenSubject(n.subject))
+	case NATSRequestReply:
+		if n.factory.Responders > 0 {
+			err = n.factory.acquireResponders()
+			n.responders = err == nil
+		}
+	case NATSQueueGroup:
+		err = n.subscribeQueueGroup()
+	default:
+		err = errors.New("requester: invalid NATS mode")
+	}
 	if err != nil {
+		n.closeMembers()
 		conn.Close()
 		return err
 	}
 	n.conn = conn
-	n.sub = sub
 	n.msg = make([]byte, n.payloadSize)
 	return nil
 }
 
+// subscribeQueueGroup connects and subscribes the members of the queue group.
+// The subscriptions are flushed, so they're in place before the first
+// request is published.
+func (n *natsRequester) subscribeQueueGroup() error {
+	size := n.factory.QueueGroupSize
+	if size < 1 {
+		size = 2
+	}
+	n.inbound = make(chan *nats.Msg, size)
+	n.members = nil
+	for i := 0; i < size; i++ {
+		member, err := nats.Connect(n.url, n.factory.options()...)
+		if err != nil {
+			return err
+		}
+		n.members = append(n.members, member)
+		if _, err := member.ChanQueueSubscribe(n.factory.listenSubject(n.subject), n.subject+"-group", n.inbound); err != nil {
+			return err
+		}
+		if err := member.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeMembers closes the connections of the queue group members, which
+// removes their subscriptions.
+func (n *natsRequester) closeMembers() {
+	for _, member := range n.members {
+		member.Close()
+	}
+	n.members = nil
+}
+
 // Request performs a synchronous request to the system under test.
 func (n *natsRequester) Request() error {
-	if err := n.conn.Publish(n.subject, n.msg); err != nil {
+	return n.ChainRequest(nil)
+}
+
+// ChainRequest performs a synchronous request to the system under test on
+// the subject completed by the values.
+func (n *natsRequester) ChainRequest(values ChainValues) error {
+	subject := n.subject
+	if n.factory.SubjectValue != "" {
+		token, ok := values[n.factory.SubjectValue]
+		if !ok {
+			return fmt.Errorf("requester: chain value %q is missing", n.factory.SubjectValue)
+		}
+		subject += "." + fmt.Sprint(token)
+	}
+
+	switch n.factory.Mode {
+	case NATSRequestReply:
+		_, err := n.conn.Request(subject, n.msg, n.timeout)
 		return err
+	case NATSQueueGroup:
+		if err := n.conn.Publish(subject, n.msg); err != nil {
+			return err
+		}
+		select {
+		case <-n.inbound:
+			return nil
+		case <-time.After(n.timeout):
+			return errors.New("requester: Request timed out receiving")
+		}
 	}
-	_, err := n.sub.NextMsg(30 * time.Second)
+	if err := n.conn.Publish(subject, n.msg); err != nil {
+		return err
+	}
+	_, err := n.sub.NextMsg(n.timeout)
 	return err
 }
 
 // Teardown is called upon benchmark completion.
 func (n *natsRequester) Teardown() error {
-	if err := n.sub.Unsubscribe(); err != nil {
-		return err
+	if n.sub != nil {
+		if err := n.sub.Unsubscribe(); err != nil {
+			return err
+		}
+	}
+	n.closeMembers()
+	if n.responders {
+		n.factory.releaseResponders()
+		n.responders = false
 	}
 	n.sub = nil
 	n.conn.Close()
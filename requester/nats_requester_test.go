@@ -0,0 +1,46 @@
+package requester_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestNATSRequester(t *testing.T) {
+	url := requestertest.NATS(t)
+	for _, mode := range []requester.NATSMode{
+		requester.NATSPublishSubscribe,
+		requester.NATSRequestReply,
+		requester.NATSQueueGroup,
+	} {
+		t.Run(mode.String(), func(t *testing.T) {
+			factory := &requester.NATSRequesterFactory{
+				URL:         url,
+				PayloadSize: 64,
+				Subject:     "bench-" + mode.String(),
+				Mode:        mode,
+				Responders:  2,
+			}
+			requestertest.Check(t, factory, 10)
+			requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+		})
+	}
+}
+
+func TestNATSRequesterSubjectValueMissing(t *testing.T) {
+	factory := &requester.NATSRequesterFactory{
+		URL:          requestertest.NATS(t),
+		Subject:      "orders",
+		SubjectValue: "id",
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	if err := r.Request(); err == nil {
+		t.Fatal("Request succeeded without the subject's chain value")
+	}
+}
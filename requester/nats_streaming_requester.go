@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/nats-io/stan.go"
 	"github.com/ssd532/bench/v2"
 )
@@ -19,41 +21,137 @@ type NATSStreamingRequesterFactory struct {
 	Subject     string
 	ClientID    string
 	URL         string
+
+	// ClusterID is the NATS Streaming cluster ID. Defaults to
+	// "test-cluster".
+	ClusterID string
+
+	// MaxPubAcksInflight limits the number of published messages awaiting
+	// an ack. Zero means the stan.go default.
+	MaxPubAcksInflight int
+
+	// SyncPublish waits for the ack of every published message. Otherwise
+	// messages are published asynchronously and the time until their ack
+	// handler is called is reported as the "stan.ack" histogram.
+	SyncPublish bool
+
+	// DurableName and QueueGroup, if set, subscribe durably and as a member
+	// of the queue group. Durable subscriptions are closed rather than
+	// unsubscribed in Teardown, so a later Benchmark resumes them. Their
+	// client IDs are ClientID followed by a dash and the connection number,
+	// since the durable state is tied to the client ID. Otherwise a unique
+	// suffix is used.
+	DurableName string
+	QueueGroup  string
+
+	// ManualAck acknowledges every received message explicitly. AckWait,
+	// if greater than zero, is the time the server waits for an ack before
+	// redelivering.
+	ManualAck bool
+	AckWait   time.Duration
+
+	// StartPosition is where subscriptions start: "new", "last", "first",
+	// "sequence", which starts at StartSequence, or "time", which starts at
+	// StartTime. Defaults to "new".
+	StartPosition string
+	StartSequence uint64
+	StartTime     time.Time
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (n *NATSStreamingRequesterFactory) GetRequester(num uint64) bench.Requester {
+	clusterID := n.ClusterID
+	if clusterID == "" {
+		clusterID = "test-cluster"
+	}
 	return &natsStreamingRequester{
 		url:         n.URL,
+		clusterID:   clusterID,
 		clientID:    n.ClientID,
+		num:         num,
 		payloadSize: n.PayloadSize,
 		subject:     n.Subject + "-" + strconv.FormatUint(num, 10),
+		factory:     n,
+	}
+}
+
+// subscriptionOptions returns the options of the subscription.
+func (n *NATSStreamingRequesterFactory) subscriptionOptions() ([]stan.SubscriptionOption, error) {
+	var opts []stan.SubscriptionOption
+	switch n.StartPosition {
+	case "", "new":
+	case "last":
+		opts = append(opts, stan.StartWithLastReceived())
+	case "first":
+		opts = append(opts, stan.DeliverAllAvailable())
+	case "sequence":
+		opts = append(opts, stan.StartAtSequence(n.StartSequence))
+	case "time":
+		opts = append(opts, stan.StartAtTime(n.StartTime))
+	default:
+		return nil, fmt.Errorf("requester: invalid NATS Streaming StartPosition %q", n.StartPosition)
+	}
+	if n.DurableName != "" {
+		opts = append(opts, stan.DurableName(n.DurableName))
+	}
+	if n.ManualAck {
+		opts = append(opts, stan.SetManualAckMode())
 	}
+	if n.AckWait > 0 {
+		opts = append(opts, stan.AckWait(n.AckWait))
+	}
+	return opts, nil
 }
 
 // natsStreamingRequester implements Requester by publishing a message to NATS
 // Streaming and waiting to receive it.
 type natsStreamingRequester struct {
 	url         string
+	clusterID   string
 	clientID    string
+	num         uint64
 	payloadSize int
 	subject     string
+	factory     *NATSStreamingRequesterFactory
 	conn        stan.Conn
 	sub         stan.Subscription
 	msg         []byte
-	msgChan     chan []byte
+	msgChan     chan *stan.Msg
+
+	// mu protects the ack results, which are recorded by ack handlers.
+	mu           sync.Mutex
+	ackHistogram *hdrhistogram.Histogram
+	ackErrors    uint64
 }
 
 // Setup prepares the Requester for benchmarking.
 func (n *natsStreamingRequester) Setup() error {
-	conn, err := stan.Connect("test-cluster", fmt.Sprintf("%s-%d", n.clientID, time.Now().UnixNano()), stan.NatsURL(n.url))
+	subOpts, err := n.factory.subscriptionOptions()
 	if err != nil {
 		return err
 	}
-	n.msgChan = make(chan []byte)
-	sub, err := conn.Subscribe(n.subject, func(msg *stan.Msg) {
-		n.msgChan <- msg.Data
-	})
+	opts := []stan.Option{stan.NatsURL(n.url)}
+	if n.factory.MaxPubAcksInflight > 0 {
+		opts = append(opts, stan.MaxPubAcksInflight(n.factory.MaxPubAcksInflight))
+	}
+	clientID := fmt.Sprintf("%s-%d", n.clientID, time.Now().UnixNano())
+	if n.factory.DurableName != "" {
+		clientID = fmt.Sprintf("%s-%d", n.clientID, n.num)
+	}
+	conn, err := stan.Connect(n.clusterID, clientID, opts...)
+	if err != nil {
+		return err
+	}
+	n.msgChan = make(chan *stan.Msg)
+	handler := func(msg *stan.Msg) {
+		n.msgChan <- msg
+	}
+	var sub stan.Subscription
+	if n.factory.QueueGroup != "" {
+		sub, err = conn.QueueSubscribe(n.subject, n.factory.QueueGroup, handler, subOpts...)
+	} else {
+		sub, err = conn.Subscribe(n.subject, handler, subOpts...)
+	}
 	if err != nil {
 		conn.Close()
 		return err
@@ -64,25 +162,68 @@ func (n *natsStreamingRequester) Setup() error {
 	for i := 0; i < n.payloadSize; i++ {
 		n.msg[i] = 'A' + uint8(rand.Intn(26))
 	}
+	n.ackHistogram = bench.NewHistogram()
+	n.ackErrors = 0
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (n *natsStreamingRequester) Request() error {
-	if _, err := n.conn.PublishAsync(n.subject, n.msg, nil); err != nil {
-		return err
+	if n.factory.SyncPublish {
+		if err := n.conn.Publish(n.subject, n.msg); err != nil {
+			return err
+		}
+	} else {
+		start := time.Now()
+		if _, err := n.conn.PublishAsync(n.subject, n.msg, func(_ string, err error) {
+			n.recordAck(time.Since(start), err)
+		}); err != nil {
+			return err
+		}
 	}
 	select {
-	case <-n.msgChan:
+	case msg := <-n.msgChan:
+		if n.factory.ManualAck {
+			return msg.Ack()
+		}
 		return nil
 	case <-time.After(30 * time.Second):
 		return errors.New("timeout")
 	}
 }
 
+// recordAck records the result of an asynchronous publish.
+func (n *natsStreamingRequester) recordAck(latency time.Duration, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err != nil {
+		n.ackErrors++
+		return
+	}
+	n.ackHistogram.RecordValue(latency.Nanoseconds())
+}
+
+// Report returns the ack latencies of asynchronous publishes.
+func (n *natsStreamingRequester) Report() *bench.Report {
+	if n.factory.SyncPublish {
+		return &bench.Report{}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return &bench.Report{
+		Histograms: map[string]*hdrhistogram.Histogram{"stan.ack": hdrhistogram.Import(n.ackHistogram.Export())},
+		Counters:   map[string]uint64{"stan.ack_errors": n.ackErrors},
+	}
+}
+
 // Teardown is called upon benchmark completion.
 func (n *natsStreamingRequester) Teardown() error {
-	if err := n.sub.Unsubscribe(); err != nil {
+	// Unsubscribing would remove a durable subscription's state.
+	closeSub := n.sub.Unsubscribe
+	if n.factory.DurableName != "" {
+		closeSub = n.sub.Close
+	}
+	if err := closeSub(); err != nil {
 		return err
 	}
 	n.sub = nil
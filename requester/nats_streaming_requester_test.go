@@ -0,0 +1,78 @@
+package requester_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/stan.go"
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestNATSStreamingRequester(t *testing.T) {
+	url := requestertest.NATSStreaming(t, "test-cluster")
+	for _, test := range []struct {
+		name    string
+		factory *requester.NATSStreamingRequesterFactory
+	}{
+		{"async", &requester.NATSStreamingRequesterFactory{}},
+		{"sync", &requester.NATSStreamingRequesterFactory{SyncPublish: true, ManualAck: true}},
+		{"queue", &requester.NATSStreamingRequesterFactory{QueueGroup: "group", StartPosition: "first"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.factory.URL = url
+			test.factory.Subject = "bench-" + test.name
+			test.factory.ClientID = "bench-" + test.name
+			test.factory.PayloadSize = 64
+			requestertest.Check(t, test.factory, 10)
+			summary := requestertest.Benchmark(t, test.factory, 2, 200*time.Millisecond)
+			if !test.factory.SyncPublish && summary.Counters["stan.ack_errors"] != 0 {
+				t.Errorf("got %d ack errors, want 0", summary.Counters["stan.ack_errors"])
+			}
+		})
+	}
+}
+
+func TestNATSStreamingRequesterDurable(t *testing.T) {
+	url := requestertest.NATSStreaming(t, "test-cluster")
+	factory := &requester.NATSStreamingRequesterFactory{
+		URL:         url,
+		Subject:     "bench",
+		ClientID:    "bench",
+		DurableName: "durable",
+		PayloadSize: 10,
+	}
+	requestertest.Check(t, factory, 3)
+
+	// The durable subscription resumes with the message published after
+	// the Requester's Teardown.
+	conn, err := stan.Connect("test-cluster", "publisher", stan.NatsURL(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Publish("bench-0", []byte("missed")); err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := stan.Connect("test-cluster", "bench-0", stan.NatsURL(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	received := make(chan *stan.Msg, 1)
+	sub, err := resumed.Subscribe("bench-0", func(msg *stan.Msg) {
+		received <- msg
+	}, stan.DurableName("durable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	select {
+	case msg := <-received:
+		if string(msg.Data) != "missed" {
+			t.Errorf("got message %q, want %q", msg.Data, "missed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("durable subscription didn't resume")
+	}
+}
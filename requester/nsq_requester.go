@@ -2,10 +2,16 @@ package requester
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/nsqio/go-nsq"
 	"github.com/ssd532/bench/v2"
 )
@@ -16,6 +22,31 @@ type NSQRequesterFactory struct {
 	URL         string
 	PayloadSize int
 	Topic       string
+
+	// LookupdHTTPAddresses, if set, discovers the nsqd instances to consume
+	// from through the given nsqlookupd HTTP addresses rather than
+	// consuming from URL directly. Discovery requires the topic to exist,
+	// so HTTPAddress should be set too.
+	LookupdHTTPAddresses []string
+
+	// HTTPAddress, if set, is the address of the nsqd HTTP API, which is
+	// used to create the topic and channel in Setup and to delete the
+	// topic in Teardown.
+	HTTPAddress string
+
+	// MultiPublish, if greater than 1, publishes the given number of
+	// messages per request with a single MPUB and waits to receive all of
+	// them.
+	MultiPublish int
+
+	// Delay, if greater than zero, publishes messages with DPUB, deferring
+	// them by Delay. How much later than requested messages are received is
+	// reported as the "nsq.delay_error" histogram.
+	Delay time.Duration
+
+	// MaxInFlight is the maximum number of messages the consumer has in
+	// flight. Defaults to 1.
+	MaxInFlight int
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
@@ -25,31 +56,54 @@ func (n *NSQRequesterFactory) GetRequester(num uint64) bench.Requester {
 		payloadSize: n.PayloadSize,
 		topic:       n.Topic + "-" + strconv.FormatUint(num, 10),
 		channel:     n.Topic + "-" + strconv.FormatUint(num, 10),
+		factory:     n,
 	}
 }
 
 // nsqRequester implements Requester by publishing a message to NSQ and
 // waiting to receive it.
 type nsqRequester struct {
-	url         string
-	payloadSize int
-	topic       string
-	channel     string
-	producer    *nsq.Producer
-	consumer    *nsq.Consumer
-	msg         []byte
-	msgChan     chan []byte
+	url            string
+	payloadSize    int
+	topic          string
+	channel        string
+	factory        *NSQRequesterFactory
+	producer       *nsq.Producer
+	consumer       *nsq.Consumer
+	msg            []byte
+	batch          [][]byte
+	msgChan        chan []byte
+	delayHistogram *hdrhistogram.Histogram
+	early          uint64
 }
 
 // Setup prepares the Requester for benchmarking.
 func (n *nsqRequester) Setup() error {
+	if n.factory.MultiPublish > 1 && n.factory.Delay > 0 {
+		return errors.New("requester: NSQ MultiPublish and Delay can't be combined")
+	}
+	if n.factory.HTTPAddress != "" {
+		params := url.Values{"topic": {n.topic}}
+		if err := n.nsqdHTTP("/topic/create", params); err != nil {
+			return err
+		}
+		params.Set("channel", n.channel)
+		if err := n.nsqdHTTP("/channel/create", params); err != nil {
+			return err
+		}
+	}
+
 	config := nsq.NewConfig()
+	if n.factory.MaxInFlight > 0 {
+		config.MaxInFlight = n.factory.MaxInFlight
+	}
 	producer, err := nsq.NewProducer(n.url, config)
 	if err != nil {
 		return err
 	}
 	consumer, err := nsq.NewConsumer(n.topic, n.channel, config)
 	if err != nil {
+		producer.Stop()
 		return err
 	}
 	n.msgChan = make(chan []byte)
@@ -58,7 +112,12 @@ func (n *nsqRequester) Setup() error {
 		n.msgChan <- m.Body
 		return nil
 	}), 1)
-	if err := consumer.ConnectToNSQD(n.url); err != nil {
+	if len(n.factory.LookupdHTTPAddresses) > 0 {
+		err = consumer.ConnectToNSQLookupds(n.factory.LookupdHTTPAddresses)
+	} else {
+		err = consumer.ConnectToNSQD(n.url)
+	}
+	if err != nil {
 		producer.Stop()
 		return err
 	}
@@ -68,28 +127,94 @@ func (n *nsqRequester) Setup() error {
 	for i := 0; i < n.payloadSize; i++ {
 		n.msg[i] = 'A' + uint8(rand.Intn(26))
 	}
+	n.batch = nil
+	for i := 0; i < n.factory.MultiPublish; i++ {
+		n.batch = append(n.batch, n.msg)
+	}
+	n.delayHistogram = bench.NewHistogram()
+	n.early = 0
+	return nil
+}
+
+// nsqdHTTP issues a POST request to the nsqd HTTP API.
+func (n *nsqRequester) nsqdHTTP(path string, params url.Values) error {
+	client := http.Client{Timeout: 30 * time.Second}
+	u := url.URL{Scheme: "http", Host: n.factory.HTTPAddress, Path: path, RawQuery: params.Encode()}
+	resp, err := client.Post(u.String(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requester: nsqd %s returned %s", path, resp.Status)
+	}
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (n *nsqRequester) Request() error {
-	if err := n.producer.Publish(n.topic, n.msg); err != nil {
+	start := time.Now()
+	messages := 1
+	var err error
+	switch {
+	case n.factory.MultiPublish > 1:
+		messages = n.factory.MultiPublish
+		err = n.producer.MultiPublish(n.topic, n.batch)
+	case n.factory.Delay > 0:
+		err = n.producer.DeferredPublish(n.topic, n.factory.Delay, n.msg)
+	default:
+		err = n.producer.Publish(n.topic, n.msg)
+	}
+	if err != nil {
 		return err
 	}
-	select {
-	case <-n.msgChan:
-		return nil
-	case <-time.After(30 * time.Second):
-		return errors.New("timeout")
+	for i := 0; i < messages; i++ {
+		select {
+		case <-n.msgChan:
+		case <-time.After(30*time.Second + n.factory.Delay):
+			return errors.New("timeout")
+		}
+	}
+	if n.factory.Delay > 0 {
+		late := time.Since(start) - n.factory.Delay
+		if late < 0 {
+			n.early++
+			late = 0
+		}
+		n.delayHistogram.RecordValue(late.Nanoseconds())
+	}
+	return nil
+}
+
+// Report returns the delay accuracy of deferred messages.
+func (n *nsqRequester) Report() *bench.Report {
+	if n.factory.Delay <= 0 {
+		return &bench.Report{}
+	}
+	return &bench.Report{
+		Histograms: map[string]*hdrhistogram.Histogram{"nsq.delay_error": n.delayHistogram},
+		Counters:   map[string]uint64{"nsq.early": n.early},
 	}
 }
 
 // Teardown is called upon benchmark completion.
 func (n *nsqRequester) Teardown() error {
-	if err := n.consumer.DisconnectFromNSQD(n.url); err != nil {
-		return err
+	n.consumer.Stop()
+	// Drain messages received late, so the handler can return.
+	for stopped := false; !stopped; {
+		select {
+		case <-n.consumer.StopChan:
+			stopped = true
+		case <-n.msgChan:
+		}
 	}
 	n.producer.Stop()
+	if n.factory.HTTPAddress != "" {
+		if err := n.nsqdHTTP("/topic/delete", url.Values{"topic": {n.topic}}); err != nil {
+			return err
+		}
+	}
 
 	n.consumer = nil
 	n.producer = nil
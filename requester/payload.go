@@ -0,0 +1,30 @@
+package requester
+
+import "math/rand"
+
+// PayloadGenerator returns the payload to send with the next request. A
+// PayloadGenerator is shared by all connections of a Benchmark, so it must be
+// safe for concurrent use.
+type PayloadGenerator func() []byte
+
+// StaticPayload returns a PayloadGenerator which always returns the given
+// payload.
+func StaticPayload(payload []byte) PayloadGenerator {
+	return func() []byte { return payload }
+}
+
+// RandomPayload returns a PayloadGenerator which returns a new payload of the
+// given size filled with random uppercase letters for every request.
+func RandomPayload(size int) PayloadGenerator {
+	return func() []byte { return randomPayload(size) }
+}
+
+// randomPayload returns a payload of the given size filled with random
+// uppercase letters.
+func randomPayload(size int) []byte {
+	msg := make([]byte, size)
+	for i := 0; i < size; i++ {
+		msg[i] = 'A' + uint8(rand.Intn(26))
+	}
+	return msg
+}
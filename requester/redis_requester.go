@@ -14,6 +14,13 @@ type RedisRequesterFactory struct {
 	URL     string
 	Command string
 	Args    []interface{}
+
+	// KeyValue and ReplyValue, if set, name ChainValues entries when the
+	// Requester is a chain step. The KeyValue entry, if present, is sent as
+	// the first argument instead of the configured one, and the reply is
+	// added under ReplyValue.
+	KeyValue   string
+	ReplyValue string
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
@@ -22,6 +29,7 @@ func (r *RedisRequesterFactory) GetRequester(uint64) bench.Requester {
 		url:     r.URL,
 		command: r.Command,
 		args:    r.Args,
+		factory: r,
 	}
 }
 
@@ -31,6 +39,7 @@ type redisRequester struct {
 	url     string
 	command string
 	args    []interface{}
+	factory *RedisRequesterFactory
 	conn    redis.Conn
 }
 
@@ -50,6 +59,28 @@ func (r *redisRequester) Request() error {
 	return err
 }
 
+// ChainRequest performs a synchronous request to the system under test with
+// the key read from the values, adding the reply to them.
+func (r *redisRequester) ChainRequest(values ChainValues) error {
+	args := r.args
+	if key, ok := values[r.factory.KeyValue]; ok && r.factory.KeyValue != "" {
+		args = append([]interface{}(nil), r.args...)
+		if len(args) == 0 {
+			args = append(args, key)
+		} else {
+			args[0] = key
+		}
+	}
+	reply, err := r.conn.Do(r.command, args...)
+	if err != nil {
+		return err
+	}
+	if r.factory.ReplyValue != "" {
+		values[r.factory.ReplyValue] = reply
+	}
+	return nil
+}
+
 // Teardown is called upon benchmark completion.
 func (r *redisRequester) Teardown() error {
 	if err := r.conn.Close(); err != nil {
@@ -97,7 +128,7 @@ func (r *redisPubSubRequester) Setup() error {
 	if err != nil {
 		return err
 	}
-	subscribeConn := &redis.PubSubConn{subConn}
+	subscribeConn := &redis.PubSubConn{Conn: subConn}
 	if err := subscribeConn.Subscribe(r.channel); err != nil {
 		subscribeConn.Close()
 		return err
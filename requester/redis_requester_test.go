@@ -0,0 +1,61 @@
+package requester_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestRedisRequester(t *testing.T) {
+	factory := &requester.RedisRequesterFactory{
+		URL:     requestertest.Redis(t),
+		Command: "SET",
+		Args:    []interface{}{"key", "value"},
+	}
+	requestertest.Check(t, factory, 10)
+	requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+}
+
+func TestRedisWorkloadRequester(t *testing.T) {
+	factory := &requester.RedisWorkloadRequesterFactory{
+		URL: requestertest.Redis(t),
+		Commands: []requester.RedisWeightedCommand{
+			{Command: "GET", Weight: 4},
+			{Command: "SET", Weight: 2},
+			{Command: "HGET"},
+			{Command: "LPUSH"},
+			{Command: "INCR"},
+		},
+		Keyspace: 100,
+		Pipeline: 4,
+		Populate: true,
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	for _, command := range []string{"GET", "SET", "HGET", "LPUSH", "INCR"} {
+		if summary.Labels[command] == nil || summary.Labels[command].SuccessTotal == 0 {
+			t.Errorf("no successful %s requests", command)
+		}
+	}
+}
+
+func TestRedisStreamsRequester(t *testing.T) {
+	url := requestertest.Redis(t)
+	for _, test := range []struct {
+		name    string
+		factory *requester.RedisStreamsRequesterFactory
+	}{
+		{"read", &requester.RedisStreamsRequesterFactory{MaxLen: 100}},
+		{"consumer group", &requester.RedisStreamsRequesterFactory{ConsumerGroup: "group", DeleteStream: true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.factory.URL = url
+			test.factory.Stream = "bench-" + test.name
+			test.factory.PayloadSize = 64
+			requestertest.Check(t, test.factory, 10)
+			requestertest.Benchmark(t, test.factory, 2, 200*time.Millisecond)
+		})
+	}
+}
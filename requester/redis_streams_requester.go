@@ -0,0 +1,219 @@
+package requester
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/ssd532/bench/v2"
+)
+
+// RedisStreamsRequesterFactory implements RequesterFactory by creating a
+// Requester which adds an entry to a Redis stream with XADD and waits to read
+// it back with XREAD, or with XREADGROUP and XACK if a consumer group is
+// configured.
+type RedisStreamsRequesterFactory struct {
+	URL         string
+	PayloadSize int
+
+	// Stream is the prefix of the streams used. Each Benchmark connection
+	// uses its own stream, named Stream followed by "-" and the connection
+	// number, e.g. "bench-0", so entries are read by the connection which
+	// added them.
+	Stream string
+
+	// ConsumerGroup, if set, reads entries as a member of the named consumer
+	// group, which is created in Setup and destroyed in Teardown, and
+	// acknowledges them.
+	ConsumerGroup string
+
+	// MaxLen, if greater than zero, trims the stream to MaxLen entries on
+	// every XADD. ApproximateTrim uses "MAXLEN ~", which is more efficient.
+	MaxLen          int64
+	ApproximateTrim bool
+
+	// DeleteStream deletes the stream in Teardown.
+	DeleteStream bool
+
+	// Timeout limits the time spent waiting to read an entry. Defaults to 30
+	// seconds.
+	Timeout time.Duration
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (r *RedisStreamsRequesterFactory) GetRequester(num uint64) bench.Requester {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &redisStreamsRequester{
+		url:             r.URL,
+		payloadSize:     r.PayloadSize,
+		stream:          r.Stream + "-" + strconv.FormatUint(num, 10),
+		group:           r.ConsumerGroup,
+		consumer:        "bench-consumer-" + strconv.FormatUint(num, 10),
+		maxLen:          r.MaxLen,
+		approximateTrim: r.ApproximateTrim,
+		deleteStream:    r.DeleteStream,
+		timeout:         timeout,
+	}
+}
+
+// redisStreamsRequester implements Requester by adding an entry to a Redis
+// stream and waiting to read it back.
+type redisStreamsRequester struct {
+	url             string
+	payloadSize     int
+	stream          string
+	group           string
+	consumer        string
+	maxLen          int64
+	approximateTrim bool
+	deleteStream    bool
+	timeout         time.Duration
+	conn            redis.Conn
+	addArgs         []interface{}
+	readArgs        []interface{}
+	lastID          string
+}
+
+// Setup prepares the Requester for benchmarking.
+func (r *redisStreamsRequester) Setup() error {
+	conn, err := redis.Dial("tcp", r.url)
+	if err != nil {
+		return err
+	}
+
+	block := strconv.FormatInt(r.timeout.Milliseconds(), 10)
+	if r.group != "" {
+		_, err := conn.Do("XGROUP", "CREATE", r.stream, r.group, "$", "MKSTREAM")
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			conn.Close()
+			return err
+		}
+		r.readArgs = []interface{}{"GROUP", r.group, r.consumer, "COUNT", 1, "BLOCK", block, "STREAMS", r.stream, ">"}
+	} else {
+		// Start reading after the current last entry of the stream.
+		entries, err := redis.Values(conn.Do("XREVRANGE", r.stream, "+", "-", "COUNT", 1))
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		r.lastID = "0-0"
+		if len(entries) > 0 {
+			id, err := streamEntryID(entries[0])
+			if err != nil {
+				conn.Close()
+				return err
+			}
+			r.lastID = id
+		}
+	}
+
+	r.addArgs = []interface{}{r.stream}
+	if r.maxLen > 0 {
+		r.addArgs = append(r.addArgs, "MAXLEN")
+		if r.approximateTrim {
+			r.addArgs = append(r.addArgs, "~")
+		}
+		r.addArgs = append(r.addArgs, r.maxLen)
+	}
+	r.addArgs = append(r.addArgs, "*", "payload", randomPayload(r.payloadSize))
+	r.conn = conn
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (r *redisStreamsRequester) Request() error {
+	if _, err := r.conn.Do("XADD", r.addArgs...); err != nil {
+		return err
+	}
+
+	var (
+		reply interface{}
+		err   error
+	)
+	if r.group != "" {
+		reply, err = r.conn.Do("XREADGROUP", r.readArgs...)
+	} else {
+		reply, err = r.conn.Do("XREAD", "COUNT", 1, "BLOCK", r.timeout.Milliseconds(), "STREAMS", r.stream, r.lastID)
+	}
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return errors.New("requester: Request timed out receiving")
+	}
+	id, err := streamReplyID(reply)
+	if err != nil {
+		return err
+	}
+
+	if r.group != "" {
+		_, err := r.conn.Do("XACK", r.stream, r.group, id)
+		return err
+	}
+	r.lastID = id
+	return nil
+}
+
+// Teardown is called upon benchmark completion.
+func (r *redisStreamsRequester) Teardown() error {
+	if r.group != "" {
+		if _, err := r.conn.Do("XGROUP", "DESTROY", r.stream, r.group); err != nil {
+			return err
+		}
+	}
+	if r.deleteStream {
+		if _, err := r.conn.Do("DEL", r.stream); err != nil {
+			return err
+		}
+	}
+	if err := r.conn.Close(); err != nil {
+		return err
+	}
+	r.conn = nil
+	return nil
+}
+
+// streamReplyID returns the ID of the first entry of an XREAD or XREADGROUP
+// reply, which has the form [[stream, [[id, [field, value, ...]], ...]], ...].
+func streamReplyID(reply interface{}) (string, error) {
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(streams) == 0 {
+		return "", errors.New("requester: empty stream reply")
+	}
+	stream, err := redis.Values(streams[0], nil)
+	if err != nil {
+		return "", err
+	}
+	if len(stream) != 2 {
+		return "", errors.New("requester: malformed stream reply")
+	}
+	entries, err := redis.Values(stream[1], nil)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", errors.New("requester: empty stream reply")
+	}
+	return streamEntryID(entries[0])
+}
+
+// streamEntryID returns the ID of a stream entry of the form
+// [id, [field, value, ...]].
+func streamEntryID(entry interface{}) (string, error) {
+	fields, err := redis.Values(entry, nil)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) == 0 {
+		return "", errors.New("requester: malformed stream entry")
+	}
+	return redis.String(fields[0], nil)
+}
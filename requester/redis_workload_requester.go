@@ -0,0 +1,265 @@
+package requester
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/ssd532/bench/v2"
+)
+
+// RedisWeightedCommand is a command issued by the Redis workload requester as
+// part of a weighted mix of commands.
+type RedisWeightedCommand struct {
+	// Command is one of GET, SET, INCR, DEL, HGET, HSET, LPUSH, RPUSH, LPOP,
+	// RPOP, SADD, SISMEMBER, ZADD or PING.
+	Command string
+
+	// Weight is the relative frequency of the command in the mix. A zero
+	// weight is treated as 1.
+	Weight uint64
+}
+
+// redisKeyPrefixes contains the key prefix of each command, so commands
+// operating on different data types use separate keys.
+var redisKeyPrefixes = map[string]string{
+	"GET":       "string:",
+	"SET":       "string:",
+	"INCR":      "counter:",
+	"DEL":       "string:",
+	"HGET":      "hash:",
+	"HSET":      "hash:",
+	"LPUSH":     "list:",
+	"RPUSH":     "list:",
+	"LPOP":      "list:",
+	"RPOP":      "list:",
+	"SADD":      "set:",
+	"SISMEMBER": "set:",
+	"ZADD":      "zset:",
+	"PING":      "",
+}
+
+// RedisWorkloadRequesterFactory implements RequesterFactory by creating a
+// Requester which issues a weighted mix of commands against a keyspace,
+// similar to redis-benchmark. Every request issues one command, chosen by
+// weight, Pipeline times and waits for all replies. The results are recorded
+// per command in Summary.Labels.
+type RedisWorkloadRequesterFactory struct {
+	URL      string
+	Commands []RedisWeightedCommand
+
+	// KeyPrefix is prepended to all keys. Defaults to "bench:".
+	KeyPrefix string
+
+	// Keyspace is the number of distinct keys per data type. Defaults to
+	// 10,000.
+	Keyspace uint64
+
+	// Keys chooses the key for every command. Defaults to UniformKeys over
+	// the keyspace.
+	Keys KeyGenerator
+
+	// ValueSize returns the size of values written. Defaults to 3 bytes, as
+	// with redis-benchmark.
+	ValueSize func() int
+
+	// Pipeline is the number of commands sent per request. Defaults to 1.
+	Pipeline int
+
+	// Populate sets every string and hash key of the keyspace before the
+	// benchmark, so reads don't miss.
+	Populate bool
+
+	once        sync.Once
+	uniformKeys KeyGenerator
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (r *RedisWorkloadRequesterFactory) GetRequester(num uint64) bench.Requester {
+	keyspace := r.Keyspace
+	if keyspace == 0 {
+		keyspace = 10000
+	}
+	keys := r.Keys
+	if keys == nil {
+		r.once.Do(func() { r.uniformKeys = UniformKeys(keyspace) })
+		keys = r.uniformKeys
+	}
+	prefix := r.KeyPrefix
+	if prefix == "" {
+		prefix = "bench:"
+	}
+	valueSize := r.ValueSize
+	if valueSize == nil {
+		valueSize = FixedSize(3)
+	}
+	pipeline := r.Pipeline
+	if pipeline < 1 {
+		pipeline = 1
+	}
+	weights := make([]uint64, len(r.Commands))
+	commands := make([]string, len(r.Commands))
+	for i, command := range r.Commands {
+		weights[i] = command.Weight
+		commands[i] = strings.ToUpper(command.Command)
+	}
+	return &redisWorkloadRequester{
+		url:       r.URL,
+		commands:  commands,
+		choice:    newWeightedChoice(weights),
+		prefix:    prefix,
+		keyspace:  keyspace,
+		keys:      keys,
+		valueSize: valueSize,
+		pipeline:  pipeline,
+		populate:  r.Populate && num == 0,
+	}
+}
+
+// redisWorkloadRequester implements Requester by issuing a weighted mix of
+// commands against a keyspace.
+type redisWorkloadRequester struct {
+	url       string
+	commands  []string
+	choice    *weightedChoice
+	prefix    string
+	keyspace  uint64
+	keys      KeyGenerator
+	valueSize func() int
+	pipeline  int
+	populate  bool
+	conn      redis.Conn
+	value     []byte
+	last      string
+}
+
+// Setup prepares the Requester for benchmarking.
+func (r *redisWorkloadRequester) Setup() error {
+	if len(r.commands) == 0 {
+		return fmt.Errorf("requester: no Redis commands configured")
+	}
+	for _, command := range r.commands {
+		if _, ok := redisKeyPrefixes[command]; !ok {
+			return fmt.Errorf("requester: unsupported Redis command %q", command)
+		}
+	}
+	conn, err := redis.Dial("tcp", r.url)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	if r.populate {
+		if err := r.populateKeyspace(); err != nil {
+			conn.Close()
+			r.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// populateKeyspace sets every string and hash key of the keyspace.
+func (r *redisWorkloadRequester) populateKeyspace() error {
+	const batch = 1000
+	for start := uint64(0); start < r.keyspace; start += batch {
+		sent := 0
+		for key := start; key < start+batch && key < r.keyspace; key++ {
+			if err := r.conn.Send("SET", r.key("GET", key), r.nextValue()); err != nil {
+				return err
+			}
+			if err := r.conn.Send("HSET", r.key("HGET", key), "field", r.nextValue()); err != nil {
+				return err
+			}
+			sent += 2
+		}
+		if err := r.conn.Flush(); err != nil {
+			return err
+		}
+		for i := 0; i < sent; i++ {
+			if _, err := r.conn.Receive(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key returns the key for the command.
+func (r *redisWorkloadRequester) key(command string, key uint64) string {
+	return r.prefix + redisKeyPrefixes[command] + strconv.FormatUint(key, 10)
+}
+
+// nextValue returns a value of the next size.
+func (r *redisWorkloadRequester) nextValue() []byte {
+	size := r.valueSize()
+	if cap(r.value) < size {
+		r.value = make([]byte, size)
+		for i := range r.value {
+			r.value[i] = 'x'
+		}
+	}
+	return r.value[:size]
+}
+
+// args returns the arguments for the command.
+func (r *redisWorkloadRequester) args(command string) []interface{} {
+	if command == "PING" {
+		return nil
+	}
+	key := r.key(command, r.keys())
+	switch command {
+	case "SET", "LPUSH", "RPUSH", "SADD":
+		return []interface{}{key, r.nextValue()}
+	case "HGET":
+		return []interface{}{key, "field"}
+	case "HSET":
+		return []interface{}{key, "field", r.nextValue()}
+	case "SISMEMBER":
+		return []interface{}{key, r.nextValue()}
+	case "ZADD":
+		return []interface{}{key, r.keys(), r.nextValue()}
+	default:
+		return []interface{}{key}
+	}
+}
+
+// Request performs a synchronous request to the system under test.
+func (r *redisWorkloadRequester) Request() error {
+	command := r.commands[r.choice.choose()]
+	r.last = command
+	if r.pipeline == 1 {
+		_, err := r.conn.Do(command, r.args(command)...)
+		return err
+	}
+	for i := 0; i < r.pipeline; i++ {
+		if err := r.conn.Send(command, r.args(command)...); err != nil {
+			return err
+		}
+	}
+	if err := r.conn.Flush(); err != nil {
+		return err
+	}
+	var firstErr error
+	for i := 0; i < r.pipeline; i++ {
+		if _, err := r.conn.Receive(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Label returns the last command issued.
+func (r *redisWorkloadRequester) Label() string {
+	return r.last
+}
+
+// Teardown is called upon benchmark completion.
+func (r *redisWorkloadRequester) Teardown() error {
+	if err := r.conn.Close(); err != nil {
+		return err
+	}
+	r.conn = nil
+	return nil
+}
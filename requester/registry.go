@@ -0,0 +1,268 @@
+package requester
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
+	"github.com/ssd532/bench/v2"
+)
+
+// FactoryFunc creates a RequesterFactory from a configuration document, e.g.
+// parsed from JSON or YAML. It returns an error if the configuration is
+// invalid.
+type FactoryFunc func(cfg map[string]interface{}) (bench.RequesterFactory, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]FactoryFunc)
+)
+
+// Register makes a requester available to NewFactory under the given name.
+// All requesters of this package are registered, see Registered. Other
+// packages can register their own requesters in an init function, so
+// importing them for their side effects makes them available:
+//
+//	import _ "example.com/bench-requester"
+//
+// Register panics if it's called twice with the same name or if fn is nil.
+func Register(name string, fn FactoryFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if fn == nil {
+		panic("requester: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("requester: Register called twice for requester " + name)
+	}
+	registry[name] = fn
+}
+
+// Registered returns the sorted names of the registered requesters.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFactory returns a RequesterFactory for the requester registered under
+// the given name, configured by cfg.
+func NewFactory(name string, cfg map[string]interface{}) (bench.RequesterFactory, error) {
+	registryMu.RLock()
+	fn, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("requester: unknown requester %q", name)
+	}
+	factory, err := fn(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("requester: %s: %v", name, err)
+	}
+	return factory, nil
+}
+
+// DecodeConfig decodes the configuration document cfg into the struct
+// pointed to by factory. Keys are matched to exported field names like
+// encoding/json does, case-insensitively, and unknown keys are an error.
+// Values are decoded like JSON values, except that string values of these
+// top-level field types are parsed:
+//
+//   - time.Duration, by time.ParseDuration, e.g. "30s".
+//   - []byte and PayloadGenerator, which use the string as the payload.
+//   - LatencyDistribution, a duration for ConstantLatency.
+//   - HTTPProtocol, "HTTP/1.1", "HTTP/2" or "h2c".
+//   - TCPFraming, "delimiter", "fixed-length" or "length-prefixed".
+//   - NATSMode, "pubsub", "request" or "queue".
+//   - stream.Compression, "none", "gzip", "snappy", "lz4" or "zstd".
+//   - KeyGenerator, "sequential(n)", "uniform(n)" or "zipfian(n, s)" for
+//     SequentialKeys, UniformKeys or ZipfianKeys.
+//   - func() int size generators, a size for FixedSize or
+//     "uniform(min, max)" for UniformSize.
+//
+// Other fields which can't be represented in a document, such as
+// ValueGenerators, keep their zero value.
+func DecodeConfig(cfg map[string]interface{}, factory interface{}) error {
+	v := reflect.ValueOf(factory)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("can't decode config into %T", factory)
+	}
+	v = v.Elem()
+
+	// Parse the string values of special fields and decode the rest as JSON.
+	rest := make(map[string]interface{}, len(cfg))
+	parsed := make(map[int]reflect.Value)
+	for key, value := range cfg {
+		s, isString := value.(string)
+		field, ok := v.Type().FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, key)
+		})
+		parse := configParsers[field.Type]
+		if !ok || field.PkgPath != "" || !isString || parse == nil || len(field.Index) != 1 {
+			rest[key] = value
+			continue
+		}
+		p, err := parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", field.Name, err)
+		}
+		parsed[field.Index[0]] = reflect.ValueOf(p).Convert(field.Type)
+	}
+
+	data, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(factory); err != nil {
+		return err
+	}
+	for i, p := range parsed {
+		v.Field(i).Set(p)
+	}
+	return nil
+}
+
+// configParsers parses string configuration values of the given field types.
+var configParsers = map[reflect.Type]func(string) (interface{}, error){
+	reflect.TypeOf(time.Duration(0)): func(s string) (interface{}, error) {
+		return time.ParseDuration(s)
+	},
+	reflect.TypeOf([]byte(nil)): func(s string) (interface{}, error) {
+		return []byte(s), nil
+	},
+	reflect.TypeOf(PayloadGenerator(nil)): func(s string) (interface{}, error) {
+		return StaticPayload([]byte(s)), nil
+	},
+	reflect.TypeOf(LatencyDistribution(nil)): func(s string) (interface{}, error) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+		return ConstantLatency(d), nil
+	},
+	reflect.TypeOf(KeyGenerator(nil)):    parseKeyGenerator,
+	reflect.TypeOf((func() int)(nil)):    parseSizeGenerator,
+	reflect.TypeOf(HTTP1):                parseName("HTTP/1.1", "HTTP/2", "h2c"),
+	reflect.TypeOf(DelimiterFraming):     parseName("delimiter", "fixed-length", "length-prefixed"),
+	reflect.TypeOf(NATSPublishSubscribe): parseName("pubsub", "request", "queue"),
+	reflect.TypeOf(stream.Compression{}): func(s string) (interface{}, error) {
+		switch strings.ToLower(s) {
+		case "none":
+			return stream.Compression{}.None(), nil
+		case "gzip":
+			return stream.Compression{}.Gzip(), nil
+		case "snappy":
+			return stream.Compression{}.Snappy(), nil
+		case "lz4":
+			return stream.Compression{}.Lz4(), nil
+		case "zstd":
+			return stream.Compression{}.Zstd(), nil
+		}
+		return nil, fmt.Errorf("unknown compression %q", s)
+	},
+}
+
+// parseKeyGenerator parses a KeyGenerator, e.g. "zipfian(1000, 1.1)".
+func parseKeyGenerator(s string) (interface{}, error) {
+	name, args, err := parseCall(s)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case name == "sequential" && len(args) == 1, name == "uniform" && len(args) == 1,
+		name == "zipfian" && len(args) == 2:
+	default:
+		return nil, fmt.Errorf("%q isn't one of sequential(n), uniform(n) or zipfian(n, s)", s)
+	}
+	n, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errors.New("the number of keys must be positive")
+	}
+	switch name {
+	case "sequential":
+		return SequentialKeys(n), nil
+	case "uniform":
+		return UniformKeys(n), nil
+	}
+	exponent, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return nil, err
+	}
+	if !(exponent > 1) {
+		return nil, fmt.Errorf("zipfian exponent %v isn't greater than 1", exponent)
+	}
+	return ZipfianKeys(n, exponent), nil
+}
+
+// parseSizeGenerator parses a size generator, e.g. "100" or
+// "uniform(10, 100)".
+func parseSizeGenerator(s string) (interface{}, error) {
+	if size, err := strconv.Atoi(s); err == nil {
+		if size < 0 {
+			return nil, fmt.Errorf("size %d is negative", size)
+		}
+		return FixedSize(size), nil
+	}
+	name, args, err := parseCall(s)
+	if err != nil {
+		return nil, err
+	}
+	if name != "uniform" || len(args) != 2 {
+		return nil, fmt.Errorf("%q isn't a size or uniform(min, max)", s)
+	}
+	min, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	max, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if min < 0 || max < min {
+		return nil, fmt.Errorf("invalid size range [%d, %d]", min, max)
+	}
+	return UniformSize(min, max), nil
+}
+
+// parseCall splits a generator such as "zipfian(1000, 1.1)" into its
+// lowercased name and arguments.
+func parseCall(s string) (string, []string, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("%q isn't of the form name(arguments)", s)
+	}
+	args := strings.Split(s[open+1:len(s)-1], ",")
+	for i, arg := range args {
+		args[i] = strings.TrimSpace(arg)
+	}
+	return strings.ToLower(strings.TrimSpace(s[:open])), args, nil
+}
+
+// parseName returns a parser for an enumeration with the given names, in the
+// order of their values.
+func parseName(names ...string) func(string) (interface{}, error) {
+	return func(s string) (interface{}, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return i, nil
+			}
+		}
+		return nil, fmt.Errorf("%q isn't one of %s", s, strings.Join(names, ", "))
+	}
+}
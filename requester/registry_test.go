@@ -0,0 +1,384 @@
+package requester_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
+	"github.com/ssd532/bench/v2"
+	"github.com/ssd532/bench/v2/requester"
+)
+
+func TestRegistered(t *testing.T) {
+	want := []string{
+		"amqp", "cassandra", "chain", "grpc", "jetstream", "kafka", "liftbridge", "mix", "nats",
+		"nats-streaming", "noop", "nsq", "redis", "redis-pubsub", "redis-streams", "redis-workload",
+		"rmqstream", "simulated", "sql", "tcp", "udp", "web", "websocket",
+	}
+	if got := requester.Registered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fn   requester.FactoryFunc
+	}{
+		{"nats", func(map[string]interface{}) (bench.RequesterFactory, error) { return nil, nil }},
+		{"new", nil},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) didn't panic", test.name)
+				}
+			}()
+			requester.Register(test.name, test.fn)
+		}()
+	}
+}
+
+func TestNewFactoryUnknown(t *testing.T) {
+	if _, err := requester.NewFactory("unknown", nil); err == nil {
+		t.Fatal("NewFactory succeeded for an unknown requester")
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		requester string
+		cfg       map[string]interface{}
+		check     func(t *testing.T, factory bench.RequesterFactory)
+	}{
+		{
+			name:      "duration",
+			requester: "nats",
+			cfg:       map[string]interface{}{"URL": "nats://localhost:4222", "Subject": "s", "Timeout": "250ms"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				if got := factory.(*requester.NATSRequesterFactory).Timeout; got != 250*time.Millisecond {
+					t.Errorf("got Timeout %s, want 250ms", got)
+				}
+			},
+		},
+		{
+			name:      "case-insensitive keys",
+			requester: "nats",
+			cfg:       map[string]interface{}{"url": "nats://localhost:4222", "SUBJECT": "s", "timeout": "1s", "payloadsize": 64},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				f := factory.(*requester.NATSRequesterFactory)
+				if f.URL != "nats://localhost:4222" || f.Subject != "s" || f.Timeout != time.Second || f.PayloadSize != 64 {
+					t.Errorf("got %+v", f)
+				}
+			},
+		},
+		{
+			name:      "NATS mode",
+			requester: "nats",
+			cfg:       map[string]interface{}{"URL": "nats://localhost:4222", "Subject": "s", "Mode": "Request"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				if got := factory.(*requester.NATSRequesterFactory).Mode; got != requester.NATSRequestReply {
+					t.Errorf("got Mode %v, want %v", got, requester.NATSRequestReply)
+				}
+			},
+		},
+		{
+			name:      "HTTP protocol and body",
+			requester: "web",
+			cfg:       map[string]interface{}{"URL": "http://localhost", "Protocol": "h2c", "Body": "hello"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				f := factory.(*requester.WebRequesterFactory)
+				if f.Protocol != requester.H2C || string(f.Body) != "hello" {
+					t.Errorf("got Protocol %v and Body %q, want %v and %q", f.Protocol, f.Body, requester.H2C, "hello")
+				}
+			},
+		},
+		{
+			name:      "TCP framing and payload",
+			requester: "tcp",
+			cfg:       map[string]interface{}{"Address": "localhost:7", "Framing": "length-prefixed", "Payload": "ping", "Delimiter": "\r\n"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				f := factory.(*requester.TCPRequesterFactory)
+				if f.Framing != requester.LengthPrefixedFraming {
+					t.Errorf("got Framing %v, want %v", f.Framing, requester.LengthPrefixedFraming)
+				}
+				if got := string(f.Payload()); got != "ping" {
+					t.Errorf("got Payload %q, want %q", got, "ping")
+				}
+				if string(f.Delimiter) != "\r\n" {
+					t.Errorf("got Delimiter %q, want %q", f.Delimiter, "\r\n")
+				}
+			},
+		},
+		{
+			name:      "compression",
+			requester: "rmqstream",
+			cfg:       map[string]interface{}{"URLs": []interface{}{"rabbitmq-stream://localhost"}, "Stream": "s", "Compression": "gzip"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				if got, want := factory.(*requester.RMQStreamRequesterFactory).Compression, (stream.Compression{}).Gzip(); !reflect.DeepEqual(got, want) {
+					t.Errorf("got Compression %+v, want %+v", got, want)
+				}
+			},
+		},
+		{
+			name:      "latency distribution",
+			requester: "simulated",
+			cfg:       map[string]interface{}{"Latency": "2ms", "StallInterval": "1s", "StallDuration": "10ms"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				f := factory.(*requester.SimulatedRequesterFactory)
+				if got := f.Latency(nil); got != 2*time.Millisecond {
+					t.Errorf("got Latency %s, want 2ms", got)
+				}
+				if f.StallInterval != time.Second || f.StallDuration != 10*time.Millisecond {
+					t.Errorf("got stalls of %s every %s, want 10ms every 1s", f.StallDuration, f.StallInterval)
+				}
+			},
+		},
+		{
+			name:      "sequential keys",
+			requester: "kafka",
+			cfg:       map[string]interface{}{"URLs": []interface{}{"localhost:9092"}, "Topic": "t", "Keys": "sequential(3)"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				keys := factory.(*requester.KafkaRequesterFactory).Keys
+				for i, want := range []uint64{0, 1, 2, 0} {
+					if got := keys(); got != want {
+						t.Errorf("got key %d = %d, want %d", i, got, want)
+					}
+				}
+			},
+		},
+		{
+			name:      "uniform keys",
+			requester: "liftbridge",
+			cfg:       map[string]interface{}{"URLs": []interface{}{"localhost:9292"}, "Stream": "s", "Keys": "uniform(5)"},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				checkKeys(t, factory.(*requester.LiftbridgeRequesterFactory).Keys, 5)
+			},
+		},
+		{
+			name:      "zipfian keys and uniform sizes",
+			requester: "redis-workload",
+			cfg: map[string]interface{}{
+				"URL":       "localhost:6379",
+				"Commands":  []interface{}{map[string]interface{}{"Command": "get"}},
+				"Keys":      "Zipfian(10, 1.5)",
+				"ValueSize": "uniform(2, 4)",
+			},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				f := factory.(*requester.RedisWorkloadRequesterFactory)
+				checkKeys(t, f.Keys, 10)
+				for i := 0; i < 100; i++ {
+					if size := f.ValueSize(); size < 2 || size > 4 {
+						t.Fatalf("got size %d, want a size in [2, 4]", size)
+					}
+				}
+			},
+		},
+		{
+			name:      "fixed size",
+			requester: "redis-workload",
+			cfg: map[string]interface{}{
+				"URL":       "localhost:6379",
+				"Commands":  []interface{}{map[string]interface{}{"Command": "SET"}},
+				"ValueSize": "7",
+			},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				if got := factory.(*requester.RedisWorkloadRequesterFactory).ValueSize(); got != 7 {
+					t.Errorf("got size %d, want 7", got)
+				}
+			},
+		},
+		{
+			name:      "mix",
+			requester: "mix",
+			cfg: map[string]interface{}{"Members": []interface{}{
+				map[string]interface{}{"Name": "read", "Weight": 3, "Requester": "noop"},
+				map[string]interface{}{"Name": "sleep", "Requester": "simulated", "Config": map[string]interface{}{"Latency": "1ms"}},
+			}},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				members := factory.(*requester.MixRequesterFactory).Members
+				if len(members) != 2 || members[0].Name != "read" || members[0].Weight != 3 || members[1].Name != "sleep" {
+					t.Fatalf("got members %+v", members)
+				}
+				if _, ok := members[0].Factory.(*requester.NOOPRequesterFactory); !ok {
+					t.Errorf("got member factory %T, want *NOOPRequesterFactory", members[0].Factory)
+				}
+				if got := members[1].Factory.(*requester.SimulatedRequesterFactory).Latency(nil); got != time.Millisecond {
+					t.Errorf("got nested Latency %s, want 1ms", got)
+				}
+			},
+		},
+		{
+			name:      "chain of mixes",
+			requester: "chain",
+			cfg: map[string]interface{}{"Steps": []interface{}{
+				map[string]interface{}{"Name": "first", "Requester": "noop"},
+				map[string]interface{}{"Name": "second", "Requester": "mix", "Config": map[string]interface{}{
+					"Members": []interface{}{map[string]interface{}{"Name": "only", "Requester": "noop"}},
+				}},
+			}},
+			check: func(t *testing.T, factory bench.RequesterFactory) {
+				steps := factory.(*requester.ChainRequesterFactory).Steps
+				if len(steps) != 2 || steps[0].Name != "first" || steps[1].Name != "second" {
+					t.Fatalf("got steps %+v", steps)
+				}
+				if _, ok := steps[1].Factory.(*requester.MixRequesterFactory); !ok {
+					t.Errorf("got step factory %T, want *MixRequesterFactory", steps[1].Factory)
+				}
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			factory, err := requester.NewFactory(test.requester, test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.check(t, factory)
+		})
+	}
+}
+
+// checkKeys reports an error if the generator returns keys outside [0, n).
+func checkKeys(t *testing.T, keys requester.KeyGenerator, n uint64) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if key := keys(); key >= n {
+			t.Fatalf("got key %d, want a key less than %d", key, n)
+		}
+	}
+}
+
+func TestNewFactoryErrors(t *testing.T) {
+	nats := func(extra map[string]interface{}) map[string]interface{} {
+		cfg := map[string]interface{}{"URL": "nats://localhost:4222", "Subject": "s"}
+		for key, value := range extra {
+			cfg[key] = value
+		}
+		return cfg
+	}
+	workload := func(extra string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"URL":      "localhost:6379",
+			"Commands": []interface{}{map[string]interface{}{"Command": "GET"}},
+			extra:      value,
+		}
+	}
+	for _, test := range []struct {
+		name      string
+		requester string
+		cfg       map[string]interface{}
+		want      string
+	}{
+		// Decoding.
+		{"unknown key", "nats", nats(map[string]interface{}{"Subjects": "s"}), `unknown field "Subjects"`},
+		{"unexported field", "redis-workload", workload("uniformKeys", "uniform(10)"), `unknown field "uniformKeys"`},
+		{"wrong type", "nats", nats(map[string]interface{}{"PayloadSize": "big"}), "cannot unmarshal"},
+		{"invalid duration", "nats", nats(map[string]interface{}{"Timeout": "soon"}), "invalid Timeout"},
+		{"invalid NATS mode", "nats", nats(map[string]interface{}{"Mode": "broadcast"}), "invalid Mode"},
+		{"invalid HTTP protocol", "web", map[string]interface{}{"URL": "http://localhost", "Protocol": "HTTP/3"}, "invalid Protocol"},
+		{"invalid TCP framing", "tcp", map[string]interface{}{"Address": "localhost:7", "Framing": "lines"}, "invalid Framing"},
+		{"invalid compression", "rmqstream", map[string]interface{}{"URLs": []interface{}{"x"}, "Stream": "s", "Compression": "brotli"}, "invalid Compression"},
+		{"invalid latency", "simulated", map[string]interface{}{"Latency": "fast"}, "invalid Latency"},
+		{"unknown key generator", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "Keys": "random(3)"}, "invalid Keys"},
+		{"key generator without keys", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "Keys": "uniform(0)"}, "invalid Keys"},
+		{"key generator arguments", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "Keys": "zipfian(10)"}, "invalid Keys"},
+		{"zipfian exponent", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "Keys": "zipfian(10, 1)"}, "invalid Keys"},
+		{"malformed key generator", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "Keys": "uniform"}, "invalid Keys"},
+		{"negative size", "redis-workload", workload("ValueSize", "-1"), "invalid ValueSize"},
+		{"size range", "redis-workload", workload("ValueSize", "uniform(4, 2)"), "invalid ValueSize"},
+		{"unknown size generator", "redis-workload", workload("ValueSize", "normal(4, 2)"), "invalid ValueSize"},
+
+		// Validation.
+		{"amqp URL", "amqp", map[string]interface{}{"Queue": "q", "Exchange": "e"}, "URL is required"},
+		{"amqp Queue", "amqp", map[string]interface{}{"URL": "amqp://x", "Exchange": "e"}, "Queue is required"},
+		{"amqp Exchange", "amqp", map[string]interface{}{"URL": "amqp://x", "Queue": "q"}, "Exchange is required"},
+		{"amqp PayloadSize", "amqp", map[string]interface{}{"URL": "amqp://x", "Queue": "q", "Exchange": "e", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"cassandra URLs", "cassandra", map[string]interface{}{"Statement": "SELECT 1"}, "URLs is required"},
+		{"cassandra Statement", "cassandra", map[string]interface{}{"URLs": []interface{}{"x"}}, "Statement or Statements is required"},
+		{"chain Steps", "chain", map[string]interface{}{}, "no chain steps configured"},
+		{"chain step Name", "chain", map[string]interface{}{"Steps": []interface{}{map[string]interface{}{"Requester": "noop"}}}, "chain step without a name"},
+		{"chain duplicate step", "chain", map[string]interface{}{"Steps": []interface{}{
+			map[string]interface{}{"Name": "a", "Requester": "noop"},
+			map[string]interface{}{"Name": "a", "Requester": "noop"},
+		}}, `duplicate chain step "a"`},
+		{"chain step Requester", "chain", map[string]interface{}{"Steps": []interface{}{map[string]interface{}{"Name": "a", "Requester": "unknown"}}}, `unknown requester "unknown"`},
+		{"chain step Config", "chain", map[string]interface{}{"Steps": []interface{}{map[string]interface{}{"Name": "a", "Requester": "nats"}}}, "nats: URL is required"},
+		{"grpc Target", "grpc", map[string]interface{}{"Method": "/s/m", "UseReflection": true}, "Target is required"},
+		{"grpc Method", "grpc", map[string]interface{}{"Target": "x", "UseReflection": true}, "Method is required"},
+		{"grpc descriptors", "grpc", map[string]interface{}{"Target": "x", "Method": "/s/m"}, "DescriptorSet or UseReflection is required"},
+		{"jetstream URL", "jetstream", map[string]interface{}{"Stream": "s"}, "URL is required"},
+		{"jetstream Stream", "jetstream", map[string]interface{}{"URL": "x"}, "Stream is required"},
+		{"jetstream PayloadSize", "jetstream", map[string]interface{}{"URL": "x", "Stream": "s", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"kafka URLs", "kafka", map[string]interface{}{"Topic": "t"}, "URLs is required"},
+		{"kafka Topic", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}}, "Topic is required"},
+		{"kafka PayloadSize", "kafka", map[string]interface{}{"URLs": []interface{}{"x"}, "Topic": "t", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"liftbridge URLs", "liftbridge", map[string]interface{}{"Stream": "s"}, "URLs is required"},
+		{"liftbridge Stream", "liftbridge", map[string]interface{}{"URLs": []interface{}{"x"}}, "Stream is required"},
+		{"liftbridge PayloadSize", "liftbridge", map[string]interface{}{"URLs": []interface{}{"x"}, "Stream": "s", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"mix Members", "mix", map[string]interface{}{}, "no mix members configured"},
+		{"mix member Name", "mix", map[string]interface{}{"Members": []interface{}{map[string]interface{}{"Requester": "noop"}}}, "mix member without a name"},
+		{"mix duplicate member", "mix", map[string]interface{}{"Members": []interface{}{
+			map[string]interface{}{"Name": "a", "Requester": "noop"},
+			map[string]interface{}{"Name": "a", "Requester": "noop"},
+		}}, `duplicate mix member "a"`},
+		{"mix member Config", "mix", map[string]interface{}{"Members": []interface{}{
+			map[string]interface{}{"Name": "a", "Requester": "noop", "Config": map[string]interface{}{"Unknown": 1}},
+		}}, `unknown field "Unknown"`},
+		{"nats URL", "nats", map[string]interface{}{"Subject": "s"}, "URL is required"},
+		{"nats Subject", "nats", map[string]interface{}{"URL": "x"}, "Subject is required"},
+		{"nats PayloadSize", "nats", nats(map[string]interface{}{"PayloadSize": -1}), "PayloadSize can't be negative"},
+		{"nats-streaming URL", "nats-streaming", map[string]interface{}{"Subject": "s", "ClientID": "c"}, "URL is required"},
+		{"nats-streaming Subject", "nats-streaming", map[string]interface{}{"URL": "x", "ClientID": "c"}, "Subject is required"},
+		{"nats-streaming ClientID", "nats-streaming", map[string]interface{}{"URL": "x", "Subject": "s"}, "ClientID is required"},
+		{"nats-streaming PayloadSize", "nats-streaming", map[string]interface{}{"URL": "x", "Subject": "s", "ClientID": "c", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"nats-streaming StartPosition", "nats-streaming", map[string]interface{}{"URL": "x", "Subject": "s", "ClientID": "c", "StartPosition": "middle"}, "invalid NATS Streaming StartPosition"},
+		{"nsq URL", "nsq", map[string]interface{}{"Topic": "t"}, "URL is required"},
+		{"nsq Topic", "nsq", map[string]interface{}{"URL": "x"}, "Topic is required"},
+		{"nsq MultiPublish", "nsq", map[string]interface{}{"URL": "x", "Topic": "t", "MultiPublish": 2, "Delay": "1s"}, "MultiPublish and Delay can't be combined"},
+		{"nsq PayloadSize", "nsq", map[string]interface{}{"URL": "x", "Topic": "t", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"redis URL", "redis", map[string]interface{}{"Command": "PING"}, "URL is required"},
+		{"redis Command", "redis", map[string]interface{}{"URL": "x"}, "Command is required"},
+		{"redis-pubsub URL", "redis-pubsub", map[string]interface{}{"Channel": "c"}, "URL is required"},
+		{"redis-pubsub Channel", "redis-pubsub", map[string]interface{}{"URL": "x"}, "Channel is required"},
+		{"redis-pubsub PayloadSize", "redis-pubsub", map[string]interface{}{"URL": "x", "Channel": "c", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"redis-streams URL", "redis-streams", map[string]interface{}{"Stream": "s"}, "URL is required"},
+		{"redis-streams Stream", "redis-streams", map[string]interface{}{"URL": "x"}, "Stream is required"},
+		{"redis-streams PayloadSize", "redis-streams", map[string]interface{}{"URL": "x", "Stream": "s", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"redis-workload URL", "redis-workload", map[string]interface{}{"Commands": []interface{}{map[string]interface{}{"Command": "GET"}}}, "URL is required"},
+		{"redis-workload Commands", "redis-workload", map[string]interface{}{"URL": "x"}, "Commands is required"},
+		{"redis-workload Command", "redis-workload", map[string]interface{}{"URL": "x", "Commands": []interface{}{map[string]interface{}{"Command": "FLUSHALL"}}}, `unsupported Redis command "FLUSHALL"`},
+		{"rmqstream URLs", "rmqstream", map[string]interface{}{"Stream": "s"}, "URLs is required"},
+		{"rmqstream Stream", "rmqstream", map[string]interface{}{"URLs": []interface{}{"x"}}, "Stream is required"},
+		{"rmqstream PayloadSize", "rmqstream", map[string]interface{}{"URLs": []interface{}{"x"}, "Stream": "s", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"rmqstream Offset", "rmqstream", map[string]interface{}{"URLs": []interface{}{"x"}, "Stream": "s", "Offset": "middle"}, `invalid RabbitMQ stream Offset "middle"`},
+		{"rmqstream OffsetTimestamp", "rmqstream", map[string]interface{}{"URLs": []interface{}{"x"}, "Stream": "s", "Offset": "timestamp"}, "requires OffsetTimestamp"},
+		{"simulated ErrorRate", "simulated", map[string]interface{}{"ErrorRate": 1.5}, "ErrorRate 1.5 isn't in [0, 1]"},
+		{"simulated ArrivalRate", "simulated", map[string]interface{}{"ArrivalRate": -1}, "ArrivalRate can't be negative"},
+		{"sql DriverName", "sql", map[string]interface{}{"DSN": "x", "Statements": []interface{}{map[string]interface{}{"Query": "SELECT 1"}}}, "DriverName is required"},
+		{"sql DSN", "sql", map[string]interface{}{"DriverName": "x", "Statements": []interface{}{map[string]interface{}{"Query": "SELECT 1"}}}, "DSN is required"},
+		{"sql Statements", "sql", map[string]interface{}{"DriverName": "x", "DSN": "x"}, "Statements is required"},
+		{"tcp Address", "tcp", map[string]interface{}{}, "Address is required"},
+		{"tcp PayloadSize", "tcp", map[string]interface{}{"Address": "x", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"udp Address", "udp", map[string]interface{}{}, "Address is required"},
+		{"udp PayloadSize", "udp", map[string]interface{}{"Address": "x", "PayloadSize": -1}, "PayloadSize can't be negative"},
+		{"web URL", "web", map[string]interface{}{}, "URL is required"},
+		{"websocket URL", "websocket", map[string]interface{}{}, "URL is required"},
+		{"websocket PayloadSize", "websocket", map[string]interface{}{"URL": "x", "PayloadSize": -1}, "PayloadSize can't be negative"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := requester.NewFactory(test.requester, test.cfg)
+			if err == nil {
+				t.Fatalf("NewFactory succeeded, want an error containing %q", test.want)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %q, want an error containing %q", err, test.want)
+			}
+			if prefix := "requester: " + test.requester + ": "; !strings.HasPrefix(err.Error(), prefix) {
+				t.Errorf("got error %q, want the prefix %q", err, prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package requestertest provides embedded servers for the systems supported
+// by the requester package and helpers which run Requesters against them, so
+// requesters can be tested without a live broker.
+//
+// A typical test starts a server and checks a factory against it:
+//
+//	func TestNATSRequester(t *testing.T) {
+//		factory := &requester.NATSRequesterFactory{
+//			URL:         requestertest.NATS(t),
+//			PayloadSize: 64,
+//			Subject:     "bench",
+//		}
+//		requestertest.Check(t, factory, 100)
+//		requestertest.Benchmark(t, factory, 2, time.Second)
+//	}
+//
+// Servers are stopped when the test completes.
+package requestertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+)
+
+// Check runs a Requester of the factory through Setup, the given number of
+// requests and Teardown, failing the test on any error.
+func Check(tb testing.TB, factory bench.RequesterFactory, requests int) {
+	tb.Helper()
+	requester := factory.GetRequester(0)
+	if err := requester.Setup(); err != nil {
+		tb.Fatalf("Setup: %v", err)
+	}
+	for i := 0; i < requests; i++ {
+		if err := requester.Request(); err != nil {
+			requester.Teardown()
+			tb.Fatalf("Request %d: %v", i, err)
+		}
+	}
+	if err := requester.Teardown(); err != nil {
+		tb.Fatalf("Teardown: %v", err)
+	}
+}
+
+// Benchmark runs a Benchmark of the factory with the given number of
+// connections for the duration at full throttle and returns its Summary,
+// failing the test if any request failed or none succeeded.
+func Benchmark(tb testing.TB, factory bench.RequesterFactory, connections uint64, duration time.Duration) *bench.Summary {
+	tb.Helper()
+	summary, err := bench.NewBenchmark(factory, 0, connections, duration, 0).Run()
+	if err != nil {
+		tb.Fatalf("Benchmark: %v", err)
+	}
+	if summary.SuccessTotal == 0 {
+		tb.Fatalf("Benchmark: no successful requests")
+	}
+	if summary.ErrorTotal > 0 {
+		tb.Fatalf("Benchmark: %d of %d requests failed", summary.ErrorTotal, summary.SuccessTotal+summary.ErrorTotal)
+	}
+	if summary.Connections != connections {
+		tb.Fatalf("Benchmark: got %d connections, want %d", summary.Connections, connections)
+	}
+	return summary
+}
@@ -0,0 +1,189 @@
+package requestertest
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	stanserver "github.com/nats-io/nats-streaming-server/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// NATS starts an embedded NATS server with JetStream enabled and returns its
+// client URL.
+func NATS(tb testing.TB) string {
+	tb.Helper()
+	server, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  tb.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		tb.Fatalf("requestertest: NATS: %v", err)
+	}
+	go server.Start()
+	tb.Cleanup(server.Shutdown)
+	if !server.ReadyForConnections(10 * time.Second) {
+		tb.Fatalf("requestertest: NATS: server not ready")
+	}
+	return server.ClientURL()
+}
+
+// NATSStreaming starts an embedded NATS Streaming server with the given
+// cluster ID and an in-memory store, backed by an embedded NATS server, and
+// returns its client URL.
+func NATSStreaming(tb testing.TB, clusterID string) string {
+	tb.Helper()
+	url := NATS(tb)
+	opts := stanserver.GetDefaultOptions()
+	opts.ID = clusterID
+	opts.NATSServerURL = url
+	server, err := stanserver.RunServerWithOpts(opts, nil)
+	if err != nil {
+		tb.Fatalf("requestertest: NATS Streaming: %v", err)
+	}
+	tb.Cleanup(server.Shutdown)
+	return url
+}
+
+// Redis starts an in-process Redis server and returns its address. It
+// supports a subset of Redis, including strings, hashes, lists, sets,
+// sorted sets, pub/sub and streams.
+func Redis(tb testing.TB) string {
+	tb.Helper()
+	server, err := miniredis.Run()
+	if err != nil {
+		tb.Fatalf("requestertest: Redis: %v", err)
+	}
+	tb.Cleanup(server.Close)
+	return server.Addr()
+}
+
+// HTTP starts an HTTP/1.1 server which echoes request bodies.
+func HTTP(tb testing.TB) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewServer(http.HandlerFunc(echo))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// HTTP2 starts a TLS server supporting HTTP/2 which echoes request bodies.
+// Its Client trusts the server's certificate.
+func HTTP2(tb testing.TB) *httptest.Server {
+	tb.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(echo))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	tb.Cleanup(server.Close)
+	return server
+}
+
+// echo writes the request body to the response.
+func echo(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+}
+
+// WebSocket starts a WebSocket server which echoes messages and returns its
+// URL.
+func WebSocket(tb testing.TB) string {
+	tb.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return "ws" + server.URL[len("http"):]
+}
+
+// GRPC starts a gRPC server with the health service and server reflection
+// and returns its address. The health service's Check method,
+// "/grpc.health.v1.Health/Check", can be used as the method under test.
+func GRPC(tb testing.TB) string {
+	tb.Helper()
+	listener := listen(tb)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go server.Serve(listener)
+	tb.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+// TCPEcho starts a TCP server which echoes everything it receives and
+// returns its address.
+func TCPEcho(tb testing.TB) string {
+	tb.Helper()
+	listener := listen(tb)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// UDPEcho starts a UDP server which echoes every datagram and returns its
+// address.
+func UDPEcho(tb testing.TB) string {
+	tb.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("requestertest: UDP: %v", err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// listen returns a TCP listener on a free loopback port, which is closed when
+// the test completes.
+func listen(tb testing.TB) net.Listener {
+	tb.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("requestertest: listen: %v", err)
+	}
+	tb.Cleanup(func() { listener.Close() })
+	return listener
+}
@@ -2,6 +2,7 @@ package requester
 
 import (
 	"errors"
+	"fmt"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/amqp"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/message"
 	"github.com/rabbitmq/rabbitmq-stream-go-client/pkg/stream"
@@ -18,51 +19,163 @@ type RMQStreamRequesterFactory struct {
 	PayloadSize int
 	Stream      string
 	DoConsume   bool
+
+	// BatchSize is the number of messages sent per request with a single
+	// BatchSend, which is also the producer's batch size. Defaults to 1.
+	BatchSize int
+
+	// SubEntrySize, if greater than 1, groups messages into sub-entries of
+	// the given size, compressed with Compression, e.g.
+	// stream.Compression{}.Gzip().
+	SubEntrySize int
+	Compression  stream.Compression
+
+	// Deduplication names the producer and assigns publishing IDs to
+	// messages, so the broker deduplicates them. It's ignored by the broker
+	// with sub-entry batching.
+	Deduplication bool
+
+	// Confirm waits for the broker's confirmation of all messages of a
+	// request, making it the latency point. Unconfirmed messages are
+	// errors.
+	Confirm bool
+
+	// MaxLengthBytes, MaxSegmentSizeBytes and MaxAge set the retention of
+	// the stream. MaxLengthBytes defaults to 2GB.
+	MaxLengthBytes      int64
+	MaxSegmentSizeBytes int64
+	MaxAge              time.Duration
+
+	// Offset is where consumers start: "first", "last", "next" or
+	// "timestamp", which starts at OffsetTimestamp and requires it to be
+	// set. Defaults to "first" for a new stream and to "next" for a stream
+	// which already exists, so its earlier messages aren't consumed as
+	// replies to requests.
+	Offset          string
+	OffsetTimestamp time.Time
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
 func (r *RMQStreamRequesterFactory) GetRequester(num uint64) bench.Requester {
+	batchSize := r.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
 	return &rmqstreamRequester{
 		urls:        r.URLs,
 		payloadSize: r.PayloadSize,
 		stream:      r.Stream + "-" + strconv.FormatUint(num, 10),
 		doConsume:   r.DoConsume,
+		batchSize:   batchSize,
+		factory:     r,
+	}
+}
+
+// offset returns the offset specification consumers start at, given whether
+// the stream already existed.
+func (r *RMQStreamRequesterFactory) offset(existed bool) (stream.OffsetSpecification, error) {
+	switch r.Offset {
+	case "":
+		if existed {
+			return stream.OffsetSpecification{}.Next(), nil
+		}
+		return stream.OffsetSpecification{}.First(), nil
+	case "first":
+		return stream.OffsetSpecification{}.First(), nil
+	case "last":
+		return stream.OffsetSpecification{}.Last(), nil
+	case "next":
+		return stream.OffsetSpecification{}.Next(), nil
+	case "timestamp":
+		if r.OffsetTimestamp.IsZero() {
+			return stream.OffsetSpecification{}, errors.New("requester: RabbitMQ stream Offset \"timestamp\" requires OffsetTimestamp")
+		}
+		return stream.OffsetSpecification{}.Timestamp(r.OffsetTimestamp.UnixNano() / int64(time.Millisecond)), nil
+	default:
+		return stream.OffsetSpecification{}, fmt.Errorf("requester: invalid RabbitMQ stream Offset %q", r.Offset)
+	}
+}
+
+// streamOptions returns the options the stream is declared with.
+func (r *RMQStreamRequesterFactory) streamOptions() *stream.StreamOptions {
+	maxLengthBytes := r.MaxLengthBytes
+	if maxLengthBytes == 0 {
+		maxLengthBytes = 2 << 30
 	}
+	options := stream.NewStreamOptions().
+		SetMaxLengthBytes(stream.ByteCapacity{}.B(maxLengthBytes)).
+		SetMaxAge(r.MaxAge)
+	if r.MaxSegmentSizeBytes > 0 {
+		options.SetMaxSegmentSizeBytes(stream.ByteCapacity{}.B(r.MaxSegmentSizeBytes))
+	}
+	return options
 }
 
 // amqpRequester implements Requester by publishing a message to an AMQP
 // exhcnage and waiting to consume it.
 type rmqstreamRequester struct {
-	urls        []string
-	payloadSize int
-	stream      string
-	producer    *stream.Producer
-	consumer    *stream.Consumer
-	msg         *amqp.AMQP10
-	inbound     chan amqp.Message
-	env         *stream.Environment
-	doConsume   bool
+	urls         []string
+	payloadSize  int
+	stream       string
+	producer     *stream.Producer
+	consumer     *stream.Consumer
+	payload      []byte
+	batch        []message.StreamMessage
+	inbound      chan amqp.Message
+	confirms     stream.ChannelPublishConfirm
+	env          *stream.Environment
+	doConsume    bool
+	batchSize    int
+	factory      *RMQStreamRequesterFactory
+	publishingID int64
 }
 
 // Setup prepares the Requester for benchmarking.
 func (r *rmqstreamRequester) Setup() error {
+	if _, err := r.factory.offset(false); err != nil {
+		return err
+	}
 	env, err := stream.NewEnvironment(
 		stream.NewEnvironmentOptions().SetUris(r.urls))
 	if err != nil {
 		return err
 	}
-	err = env.DeclareStream(r.stream,
-		&stream.StreamOptions{
-			MaxLengthBytes: stream.ByteCapacity{}.GB(2),
-		},
-	)
+	err = env.DeclareStream(r.stream, r.factory.streamOptions())
+	if err != nil && err != stream.StreamAlreadyExists {
+		env.Close()
+		return err
+	}
+	offset, err := r.factory.offset(err == stream.StreamAlreadyExists)
 	if err != nil {
+		env.Close()
 		return err
 	}
-	producer, err := env.NewProducer(r.stream, stream.NewProducerOptions().SetBatchSize(1))
+
+	options := stream.NewProducerOptions().SetBatchSize(r.batchSize)
+	if r.factory.SubEntrySize > 1 {
+		options.SetSubEntrySize(r.factory.SubEntrySize).SetCompression(r.factory.Compression)
+	}
+	if r.factory.Deduplication {
+		options.SetProducerName(r.stream + "-producer")
+	}
+	producer, err := env.NewProducer(r.stream, options)
 	if err != nil {
+		env.Close()
 		return err
 	}
+	r.publishingID = 0
+	if r.factory.Deduplication {
+		last, err := producer.GetLastPublishingId()
+		if err != nil {
+			env.Close()
+			return err
+		}
+		r.publishingID = last + 1
+	}
+	r.confirms = nil
+	if r.factory.Confirm {
+		r.confirms = producer.NotifyPublishConfirmation()
+	}
 
 	r.inbound = make(chan amqp.Message)
 	handleMessages := func(consumerContext stream.ConsumerContext, message *amqp.Message) {
@@ -76,9 +189,10 @@ func (r *rmqstreamRequester) Setup() error {
 			handleMessages,
 			stream.NewConsumerOptions().
 				SetConsumerName("benchmark_consumer").
-				SetOffset(stream.OffsetSpecification{}.First()))
+				SetOffset(offset))
 		if err != nil {
 			r.inbound = nil
+			env.Close()
 			return err
 		}
 	}
@@ -90,22 +204,58 @@ func (r *rmqstreamRequester) Setup() error {
 	for i := 0; i < r.payloadSize; i++ {
 		msg[i] = 'A' + uint8(rand.Intn(26))
 	}
-	r.msg = amqp.NewMessage(msg)
+	r.payload = msg
+	r.batch = make([]message.StreamMessage, r.batchSize)
 	return nil
 }
 
 // Request performs a synchronous request to the system under test.
 func (r *rmqstreamRequester) Request() error {
-	if err := r.producer.BatchSend([]message.StreamMessage{r.msg}); err != nil {
+	for i := range r.batch {
+		msg := amqp.NewMessage(r.payload)
+		if r.factory.Deduplication {
+			msg.SetPublishingId(r.publishingID)
+			r.publishingID++
+		}
+		r.batch[i] = msg
+	}
+	if err := r.producer.BatchSend(r.batch); err != nil {
 		return err
 	}
+	if r.confirms != nil {
+		if err := r.awaitConfirms(); err != nil {
+			return err
+		}
+	}
 	if r.doConsume {
+		for i := 0; i < r.batchSize; i++ {
+			select {
+			case <-r.inbound:
+			case <-time.After(30 * time.Second):
+				return errors.New("requester: Request timed out receiving")
+			}
+		}
+	}
+	return nil
+}
+
+// awaitConfirms waits for the confirmation of all messages of the batch.
+func (r *rmqstreamRequester) awaitConfirms() error {
+	for pending := len(r.batch); pending > 0; {
 		select {
-		case <-r.inbound:
+		case statuses, ok := <-r.confirms:
+			if !ok {
+				return errors.New("requester: producer closed awaiting confirm")
+			}
+			for _, status := range statuses {
+				if !status.IsConfirmed() {
+					return fmt.Errorf("requester: message not confirmed: %v", status.GetError())
+				}
+			}
+			pending -= len(statuses)
 		case <-time.After(30 * time.Second):
-			return errors.New("requester: Request timed out receiving")
+			return errors.New("requester: Request timed out awaiting confirm")
 		}
-
 	}
 	return nil
 }
@@ -131,5 +281,6 @@ func (r *rmqstreamRequester) Teardown() error {
 	r.producer = nil
 	r.env = nil
 	r.inbound = nil
+	r.confirms = nil
 	return nil
 }
@@ -0,0 +1,191 @@
+package requester
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/ssd532/bench/v2"
+)
+
+// ErrSimulated is returned by simulated requests which fail.
+var ErrSimulated = errors.New("requester: simulated error")
+
+// LatencyDistribution returns the service time of a simulated request, drawn
+// using the given source of random numbers.
+type LatencyDistribution func(r *rand.Rand) time.Duration
+
+// ConstantLatency returns a LatencyDistribution which always returns d.
+func ConstantLatency(d time.Duration) LatencyDistribution {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// NormalLatency returns a LatencyDistribution following a normal
+// distribution with the given mean and standard deviation. Negative values
+// are returned as zero.
+func NormalLatency(mean, stddev time.Duration) LatencyDistribution {
+	return func(r *rand.Rand) time.Duration {
+		d := time.Duration(r.NormFloat64()*float64(stddev)) + mean
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+}
+
+// LogNormalLatency returns a LatencyDistribution following a log-normal
+// distribution with the given median and shape sigma, which produces the long
+// tail typical of service latencies. A typical value for sigma is 0.5.
+func LogNormalLatency(median time.Duration, sigma float64) LatencyDistribution {
+	mu := math.Log(float64(median))
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(math.Exp(mu + sigma*r.NormFloat64()))
+	}
+}
+
+// BimodalLatency returns a LatencyDistribution which draws from slow with
+// probability slowFraction and from fast otherwise, e.g. to simulate cache
+// misses.
+func BimodalLatency(fast, slow LatencyDistribution, slowFraction float64) LatencyDistribution {
+	return func(r *rand.Rand) time.Duration {
+		if r.Float64() < slowFraction {
+			return slow(r)
+		}
+		return fast(r)
+	}
+}
+
+// SimulatedRequesterFactory implements RequesterFactory by creating a
+// Requester which simulates a system under test with known behavior, for
+// validating the benchmark results. Every request sleeps for its simulated
+// latency. The simulated latencies are reported as the "simulated"
+// histogram, which is the ground truth for the corrected latencies.
+type SimulatedRequesterFactory struct {
+	// Latency is the distribution of service times. Defaults to no latency.
+	Latency LatencyDistribution
+
+	// ErrorRate is the fraction of requests, in [0, 1], which fail with
+	// ErrSimulated.
+	ErrorRate float64
+
+	// StallInterval and StallDuration, if both greater than zero, simulate
+	// a system which stalls for StallDuration at the start of every
+	// StallInterval, e.g. for garbage collection. Requests issued during a
+	// stall wait until it ends. Stalls are shared by all connections and
+	// begin with the first Setup.
+	StallInterval time.Duration
+	StallDuration time.Duration
+
+	// ArrivalRate, if greater than zero, simulates a single-server FIFO
+	// queue per connection with Poisson arrivals at ArrivalRate requests per
+	// second. The latency of a request is then its waiting time in the
+	// queue plus its service time. With ConstantLatency(D) this is an M/D/1
+	// queue with utilization ρ = ArrivalRate·D, which must be below 1, and a
+	// mean latency of D + ρD/(2(1-ρ)).
+	ArrivalRate float64
+
+	// Seed seeds the random numbers of connection n with Seed+n, so
+	// simulated latencies and errors are deterministic.
+	Seed int64
+
+	once  sync.Once
+	epoch time.Time
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (s *SimulatedRequesterFactory) GetRequester(num uint64) bench.Requester {
+	return &simulatedRequester{
+		factory: s,
+		seed:    s.Seed + int64(num),
+	}
+}
+
+// stall returns the time remaining until the current stall ends, or zero if
+// the system isn't stalled.
+func (s *SimulatedRequesterFactory) stall(now time.Time) time.Duration {
+	if s.StallInterval <= 0 || s.StallDuration <= 0 {
+		return 0
+	}
+	offset := now.Sub(s.epoch) % s.StallInterval
+	if offset >= s.StallDuration {
+		return 0
+	}
+	return s.StallDuration - offset
+}
+
+// simulatedRequester implements Requester by sleeping for a simulated
+// latency.
+type simulatedRequester struct {
+	factory   *SimulatedRequesterFactory
+	seed      int64
+	rand      *rand.Rand
+	wait      time.Duration
+	histogram *hdrhistogram.Histogram
+	errors    uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (s *simulatedRequester) Setup() error {
+	s.factory.once.Do(func() { s.factory.epoch = time.Now() })
+	s.rand = rand.New(rand.NewSource(s.seed))
+	s.wait = 0
+	s.histogram = bench.NewHistogram()
+	s.errors = 0
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (s *simulatedRequester) Request() error {
+	start := time.Now()
+	var service time.Duration
+	if s.factory.Latency != nil {
+		service = s.factory.Latency(s.rand)
+	}
+	latency := service
+	if s.factory.ArrivalRate > 0 {
+		latency += s.queue(service)
+	}
+	latency += s.factory.stall(start)
+	failed := s.factory.ErrorRate > 0 && s.rand.Float64() < s.factory.ErrorRate
+
+	if latency > 0 {
+		time.Sleep(latency - time.Since(start))
+	}
+	s.histogram.RecordValue(latency.Nanoseconds())
+	if failed {
+		s.errors++
+		return ErrSimulated
+	}
+	return nil
+}
+
+// queue returns the waiting time of the next arrival to the simulated queue
+// with the given service time. It follows Lindley's recursion: an arrival
+// waits for the remaining work of its predecessor, which is the predecessor's
+// waiting and service time less the time between their arrivals.
+func (s *simulatedRequester) queue(service time.Duration) time.Duration {
+	wait := s.wait
+	interarrival := time.Duration(s.rand.ExpFloat64() / s.factory.ArrivalRate * float64(time.Second))
+	s.wait = wait + service - interarrival
+	if s.wait < 0 {
+		s.wait = 0
+	}
+	return wait
+}
+
+// Report returns the simulated latencies and the number of simulated errors.
+func (s *simulatedRequester) Report() *bench.Report {
+	return &bench.Report{
+		Histograms: map[string]*hdrhistogram.Histogram{"simulated": s.histogram},
+		Counters:   map[string]uint64{"simulated.errors": s.errors},
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (s *simulatedRequester) Teardown() error {
+	s.rand = nil
+	return nil
+}
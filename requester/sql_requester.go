@@ -0,0 +1,207 @@
+package requester
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+)
+
+// SQLStatement is a statement executed by the SQL requester.
+type SQLStatement struct {
+	// Query is the statement to prepare.
+	Query string
+
+	// Args, if set, is called for every execution to produce the statement's
+	// parameters. It's shared by all connections of a Benchmark, so it must
+	// be safe for concurrent use.
+	Args func() []interface{}
+
+	// Exec executes the statement without returning rows, e.g. for INSERT or
+	// UPDATE statements. Otherwise the statement is queried and all returned
+	// rows are read.
+	Exec bool
+}
+
+// SQLRequesterFactory implements RequesterFactory by creating a Requester
+// which executes prepared statements using database/sql. Every request
+// executes each of the statements in order. The driver must be registered by
+// importing it, e.g. _ "github.com/lib/pq". Each Benchmark connection uses a
+// single database connection, on which the statements are prepared once and
+// reused by every request, including within transactions. The connection is
+// held by a *sql.DB limited to it rather than pinned as a *sql.Conn, since
+// Tx.StmtContext prepares statements of a *sql.Conn again in every
+// transaction.
+type SQLRequesterFactory struct {
+	DriverName string
+	DSN        string
+	Statements []SQLStatement
+
+	// Transaction wraps the statements of every request in a transaction
+	// using TxOptions.
+	Transaction bool
+	TxOptions   *sql.TxOptions
+
+	// Timeout limits the time taken by a single request. Zero means no
+	// timeout.
+	Timeout time.Duration
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (s *SQLRequesterFactory) GetRequester(uint64) bench.Requester {
+	return &sqlRequester{
+		driverName:  s.DriverName,
+		dsn:         s.DSN,
+		statements:  s.Statements,
+		transaction: s.Transaction,
+		txOptions:   s.TxOptions,
+		timeout:     s.Timeout,
+	}
+}
+
+// sqlRequester implements Requester by executing prepared statements on a
+// database limited to a single connection.
+type sqlRequester struct {
+	driverName   string
+	dsn          string
+	statements   []SQLStatement
+	transaction  bool
+	txOptions    *sql.TxOptions
+	timeout      time.Duration
+	db           *sql.DB
+	prepared     []*sql.Stmt
+	rows         uint64
+	rowsAffected uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (s *sqlRequester) Setup() error {
+	if len(s.statements) == 0 {
+		return errors.New("requester: no SQL statements configured")
+	}
+	db, err := sql.Open(s.driverName, s.dsn)
+	if err != nil {
+		return err
+	}
+	// With a single connection, which is kept idle between requests and
+	// never expires, the statements stay prepared on it and transactions
+	// reuse them rather than preparing them again. This pins the
+	// connection like a *sql.Conn would, whose statements transactions
+	// can't reuse.
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	prepared := make([]*sql.Stmt, len(s.statements))
+	for i, statement := range s.statements {
+		stmt, err := db.PrepareContext(ctx, statement.Query)
+		if err != nil {
+			db.Close()
+			return err
+		}
+		prepared[i] = stmt
+	}
+	s.db = db
+	s.prepared = prepared
+	s.rows = 0
+	s.rowsAffected = 0
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (s *sqlRequester) Request() error {
+	ctx := context.Background()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	if !s.transaction {
+		for i, stmt := range s.prepared {
+			if err := s.execute(ctx, stmt, s.statements[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, s.txOptions)
+	if err != nil {
+		return err
+	}
+	for i, stmt := range s.prepared {
+		if err := s.execute(ctx, tx.StmtContext(ctx, stmt), s.statements[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// execute executes a single prepared statement, reading all returned rows.
+func (s *sqlRequester) execute(ctx context.Context, stmt *sql.Stmt, statement SQLStatement) error {
+	var args []interface{}
+	if statement.Args != nil {
+		args = statement.Args()
+	}
+
+	if statement.Exec {
+		result, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+			s.rowsAffected += uint64(affected)
+		}
+		return nil
+	}
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(sql.RawBytes)
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		s.rows++
+	}
+	return rows.Err()
+}
+
+// Report returns the number of rows read and affected.
+func (s *sqlRequester) Report() *bench.Report {
+	return &bench.Report{Counters: map[string]uint64{
+		"sql.rows":          s.rows,
+		"sql.rows_affected": s.rowsAffected,
+	}}
+}
+
+// Teardown is called upon benchmark completion.
+func (s *sqlRequester) Teardown() error {
+	for _, stmt := range s.prepared {
+		if err := stmt.Close(); err != nil {
+			return err
+		}
+	}
+	s.prepared = nil
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	s.db = nil
+	return nil
+}
@@ -0,0 +1,114 @@
+package requester_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+// prepares counts the statements prepared through the "sqlite3-counting"
+// driver.
+var prepares int64
+
+func init() {
+	sql.Register("sqlite3-counting", countingDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// countingDriver wraps a driver to count prepared statements.
+type countingDriver struct {
+	driver.Driver
+}
+
+func (d countingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c countingConn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&prepares, 1)
+	return c.Conn.Prepare(query)
+}
+
+// sqliteDatabase creates a SQLite database with a table of counters and
+// returns its DSN.
+func sqliteDatabase(t *testing.T) string {
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO counters VALUES (1, 0), (2, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	return dsn
+}
+
+// sqlStatements increments a counter and reads all counters.
+var sqlStatements = []requester.SQLStatement{
+	{
+		Query: "UPDATE counters SET value = value + 1 WHERE id = ?",
+		Args:  func() []interface{} { return []interface{}{1} },
+		Exec:  true,
+	},
+	{Query: "SELECT id, value FROM counters"},
+}
+
+func TestSQLRequester(t *testing.T) {
+	for _, transaction := range []bool{false, true} {
+		factory := &requester.SQLRequesterFactory{
+			DriverName:  "sqlite3",
+			DSN:         sqliteDatabase(t),
+			Statements:  sqlStatements,
+			Transaction: transaction,
+		}
+		requestertest.Check(t, factory, 10)
+		summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+		if got, want := summary.Counters["sql.rows_affected"], summary.SuccessTotal; got != want {
+			t.Errorf("Transaction %v: got %d rows affected, want %d", transaction, got, want)
+		}
+		if got, want := summary.Counters["sql.rows"], 2*summary.SuccessTotal; got != want {
+			t.Errorf("Transaction %v: got %d rows, want %d", transaction, got, want)
+		}
+	}
+}
+
+func TestSQLRequesterPreparesOnce(t *testing.T) {
+	factory := &requester.SQLRequesterFactory{
+		DriverName:  "sqlite3-counting",
+		DSN:         sqliteDatabase(t),
+		Statements:  sqlStatements,
+		Transaction: true,
+	}
+	r := factory.GetRequester(0)
+	atomic.StoreInt64(&prepares, 0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	for i := 0; i < 10; i++ {
+		if err := r.Request(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := atomic.LoadInt64(&prepares), int64(len(sqlStatements)); got != want {
+		t.Errorf("got %d prepared statements, want %d", got, want)
+	}
+}
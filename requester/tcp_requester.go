@@ -0,0 +1,296 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+)
+
+// TCPFraming determines how the TCP requester delimits responses.
+type TCPFraming int
+
+const (
+	// DelimiterFraming reads the response until Delimiter. The delimiter is
+	// appended to requests which don't already end with it.
+	DelimiterFraming TCPFraming = iota
+
+	// FixedLengthFraming reads ResponseLength bytes, or as many bytes as the
+	// request if ResponseLength is zero.
+	FixedLengthFraming
+
+	// LengthPrefixedFraming reads a big-endian length prefix of
+	// LengthPrefixSize bytes followed by that many bytes. Requests are
+	// prefixed with their length in the same way.
+	LengthPrefixedFraming
+)
+
+// TCPRequesterFactory implements RequesterFactory by creating a Requester
+// which writes a payload to a TCP connection and reads the response. Each
+// Benchmark connection uses its own TCP connection, which is closed and dialed
+// again for the next request after a failed request.
+type TCPRequesterFactory struct {
+	Address string
+
+	// PayloadSize is the size of the random payload sent with every request.
+	// It's ignored if Payload is set.
+	PayloadSize int
+
+	// Payload, if set, is called for every request to produce the payload.
+	Payload PayloadGenerator
+
+	// Framing determines how responses are delimited. Defaults to
+	// DelimiterFraming.
+	Framing TCPFraming
+
+	// Delimiter terminates responses with DelimiterFraming. Defaults to a
+	// newline.
+	Delimiter []byte
+
+	// ResponseLength is the response size with FixedLengthFraming.
+	ResponseLength int
+
+	// LengthPrefixSize is the size of the length prefix with
+	// LengthPrefixedFraming, one of 1, 2, 4 or 8. Defaults to 4. Requests
+	// with payloads too long for the prefix fail.
+	LengthPrefixSize int
+
+	// Timeout limits the time taken by a single request. Defaults to 30
+	// seconds.
+	Timeout time.Duration
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (t *TCPRequesterFactory) GetRequester(uint64) bench.Requester {
+	delimiter := t.Delimiter
+	if len(delimiter) == 0 {
+		delimiter = []byte("\n")
+	}
+	prefixSize := t.LengthPrefixSize
+	if prefixSize == 0 {
+		prefixSize = 4
+	}
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &tcpRequester{
+		address:        t.Address,
+		payloadSize:    t.PayloadSize,
+		payload:        t.Payload,
+		framing:        t.Framing,
+		delimiter:      delimiter,
+		responseLength: t.ResponseLength,
+		prefixSize:     prefixSize,
+		timeout:        timeout,
+	}
+}
+
+// tcpRequester implements Requester by writing a payload to a TCP connection
+// and reading the response.
+type tcpRequester struct {
+	address        string
+	payloadSize    int
+	payload        PayloadGenerator
+	framing        TCPFraming
+	delimiter      []byte
+	responseLength int
+	prefixSize     int
+	timeout        time.Duration
+	conn           net.Conn
+	reader         *bufio.Reader
+	msg            []byte
+	buf            []byte
+}
+
+// Setup prepares the Requester for benchmarking.
+func (t *tcpRequester) Setup() error {
+	switch t.prefixSize {
+	case 1, 2, 4, 8:
+	default:
+		return fmt.Errorf("requester: invalid LengthPrefixSize %d", t.prefixSize)
+	}
+	if t.framing == LengthPrefixedFraming && t.payload == nil && uint64(t.payloadSize) > maxLength(t.prefixSize) {
+		return fmt.Errorf("requester: PayloadSize %d exceeds the maximum length of a %d-byte prefix",
+			t.payloadSize, t.prefixSize)
+	}
+	if err := t.dial(); err != nil {
+		return err
+	}
+	t.msg = randomPayload(t.payloadSize)
+	return nil
+}
+
+// dial opens the TCP connection.
+func (t *tcpRequester) dial() error {
+	conn, err := net.DialTimeout("tcp", t.address, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (t *tcpRequester) Request() error {
+	payload := t.msg
+	if t.payload != nil {
+		payload = t.payload()
+	}
+	request, err := t.frame(payload)
+	if err != nil {
+		return err
+	}
+	if t.conn == nil {
+		if err := t.dial(); err != nil {
+			return err
+		}
+	}
+	if err := t.roundTrip(request, len(payload)); err != nil {
+		// The stream may be left in the middle of a response, so it can't be
+		// used for further requests.
+		t.conn.Close()
+		t.conn = nil
+		t.reader = nil
+		return err
+	}
+	return nil
+}
+
+// roundTrip writes the request and reads the response.
+func (t *tcpRequester) roundTrip(request []byte, payloadSize int) error {
+	if err := t.conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return err
+	}
+	if _, err := t.conn.Write(request); err != nil {
+		return err
+	}
+	return t.readResponse(payloadSize)
+}
+
+// frame returns the request to write for the given payload.
+func (t *tcpRequester) frame(payload []byte) ([]byte, error) {
+	switch t.framing {
+	case DelimiterFraming:
+		if !bytes.HasSuffix(payload, t.delimiter) {
+			t.buf = append(append(t.buf[:0], payload...), t.delimiter...)
+			return t.buf, nil
+		}
+	case LengthPrefixedFraming:
+		if uint64(len(payload)) > maxLength(t.prefixSize) {
+			return nil, fmt.Errorf("requester: payload of %d bytes exceeds the maximum length of a %d-byte prefix",
+				len(payload), t.prefixSize)
+		}
+		t.buf = append(t.buf[:0], make([]byte, t.prefixSize)...)
+		putLength(t.buf, uint64(len(payload)))
+		t.buf = append(t.buf, payload...)
+		return t.buf, nil
+	}
+	return payload, nil
+}
+
+// readResponse reads a single framed response. The request size is used as
+// the response size for FixedLengthFraming if no ResponseLength is set.
+func (t *tcpRequester) readResponse(requestSize int) error {
+	switch t.framing {
+	case DelimiterFraming:
+		return t.readUntilDelimiter()
+	case FixedLengthFraming:
+		size := t.responseLength
+		if size == 0 {
+			size = requestSize
+		}
+		_, err := t.reader.Discard(size)
+		return err
+	case LengthPrefixedFraming:
+		prefix := make([]byte, t.prefixSize)
+		if _, err := io.ReadFull(t.reader, prefix); err != nil {
+			return err
+		}
+		_, err := t.reader.Discard(int(getLength(prefix)))
+		return err
+	default:
+		return fmt.Errorf("requester: invalid TCPFraming %d", t.framing)
+	}
+}
+
+// readUntilDelimiter reads until the delimiter has been received.
+func (t *tcpRequester) readUntilDelimiter() error {
+	last := t.delimiter[len(t.delimiter)-1]
+	var read []byte
+	for {
+		chunk, err := t.reader.ReadSlice(last)
+		if err == bufio.ErrBufferFull {
+			read = append(read, chunk...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(t.delimiter) == 1 {
+			return nil
+		}
+		read = append(read, chunk...)
+		if bytes.HasSuffix(read, t.delimiter) {
+			return nil
+		}
+	}
+}
+
+// Teardown is called upon benchmark completion.
+func (t *tcpRequester) Teardown() error {
+	if t.conn == nil {
+		return nil
+	}
+	if err := t.conn.Close(); err != nil {
+		return err
+	}
+	t.conn = nil
+	t.reader = nil
+	return nil
+}
+
+// maxLength returns the maximum length encoded by a length prefix of the given
+// size.
+func maxLength(prefixSize int) uint64 {
+	if prefixSize >= 8 {
+		return math.MaxUint64
+	}
+	return 1<<(8*uint(prefixSize)) - 1
+}
+
+// putLength writes the length as a big-endian integer filling the buffer.
+func putLength(buf []byte, length uint64) {
+	switch len(buf) {
+	case 1:
+		buf[0] = byte(length)
+	case 2:
+		binary.BigEndian.PutUint16(buf, uint16(length))
+	case 4:
+		binary.BigEndian.PutUint32(buf, uint32(length))
+	case 8:
+		binary.BigEndian.PutUint64(buf, length)
+	}
+}
+
+// getLength reads a big-endian integer filling the buffer.
+func getLength(buf []byte) uint64 {
+	switch len(buf) {
+	case 1:
+		return uint64(buf[0])
+	case 2:
+		return uint64(binary.BigEndian.Uint16(buf))
+	case 4:
+		return uint64(binary.BigEndian.Uint32(buf))
+	case 8:
+		return binary.BigEndian.Uint64(buf)
+	}
+	return 0
+}
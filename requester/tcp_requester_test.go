@@ -0,0 +1,104 @@
+package requester_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestTCPRequester(t *testing.T) {
+	address := requestertest.TCPEcho(t)
+	for _, test := range []struct {
+		name    string
+		factory *requester.TCPRequesterFactory
+	}{
+		{"delimiter", &requester.TCPRequesterFactory{Address: address, Payload: func() []byte { return []byte("hello") }}},
+		{"fixed length", &requester.TCPRequesterFactory{Address: address, PayloadSize: 100, Framing: requester.FixedLengthFraming}},
+		{"length prefixed", &requester.TCPRequesterFactory{Address: address, PayloadSize: 1000, Framing: requester.LengthPrefixedFraming, LengthPrefixSize: 2}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			requestertest.Check(t, test.factory, 10)
+			requestertest.Benchmark(t, test.factory, 2, 200*time.Millisecond)
+		})
+	}
+}
+
+func TestTCPRequesterPayloadTooLong(t *testing.T) {
+	factory := &requester.TCPRequesterFactory{
+		Address:          requestertest.TCPEcho(t),
+		PayloadSize:      256,
+		Framing:          requester.LengthPrefixedFraming,
+		LengthPrefixSize: 1,
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err == nil {
+		r.Teardown()
+		t.Fatal("Setup succeeded with a payload too long for the prefix")
+	}
+
+	factory.PayloadSize = 0
+	factory.Payload = func() []byte { return make([]byte, 256) }
+	r = factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	if err := r.Request(); err == nil {
+		t.Fatal("Request succeeded with a payload too long for the prefix")
+	}
+}
+
+func TestTCPRequesterRedials(t *testing.T) {
+	// The server closes every connection after two responses.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conns := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- struct{}{}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for i := 0; i < 2; i++ {
+					line, err := reader.ReadBytes('\n')
+					if err != nil {
+						return
+					}
+					if _, err := conn.Write(line); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	factory := &requester.TCPRequesterFactory{
+		Address: listener.Addr().String(),
+		Payload: func() []byte { return []byte("hello") },
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	for i := 0; i < 6; i++ {
+		err := r.Request()
+		if failed := i%3 == 2; failed != (err != nil) {
+			t.Errorf("Request %d: got error %v", i, err)
+		}
+	}
+	if got := len(conns); got != 2 {
+		t.Errorf("got %d connections, want 2", got)
+	}
+}
@@ -0,0 +1,137 @@
+package requester
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ssd532/bench/v2"
+)
+
+// errDatagramLost is returned when no reply to a datagram is received within
+// the timeout.
+var errDatagramLost = errors.New("requester: datagram lost")
+
+// UDPRequesterFactory implements RequesterFactory by creating a Requester
+// which sends a datagram and waits for a reply. Datagrams without a reply
+// within the timeout are counted as lost and reported as errors.
+type UDPRequesterFactory struct {
+	Address string
+
+	// PayloadSize is the size of the random payload sent with every request.
+	// It's ignored if Payload is set.
+	PayloadSize int
+
+	// Payload, if set, is called for every request to produce the payload.
+	Payload PayloadGenerator
+
+	// Sequence prefixes every datagram with an 8-byte big-endian sequence
+	// number and only accepts replies starting with the same sequence
+	// number. Late replies to earlier datagrams are counted and skipped.
+	Sequence bool
+
+	// Timeout is the time to wait for a reply before the datagram is
+	// considered lost. Defaults to one second.
+	Timeout time.Duration
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (u *UDPRequesterFactory) GetRequester(uint64) bench.Requester {
+	timeout := u.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	return &udpRequester{
+		address:     u.Address,
+		payloadSize: u.PayloadSize,
+		payload:     u.Payload,
+		sequence:    u.Sequence,
+		timeout:     timeout,
+	}
+}
+
+// udpRequester implements Requester by sending a datagram and waiting for a
+// reply.
+type udpRequester struct {
+	address     string
+	payloadSize int
+	payload     PayloadGenerator
+	sequence    bool
+	timeout     time.Duration
+	conn        net.Conn
+	msg         []byte
+	buf         []byte
+	readBuf     []byte
+	seq         uint64
+	lost        uint64
+	late        uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (u *udpRequester) Setup() error {
+	conn, err := net.Dial("udp", u.address)
+	if err != nil {
+		return err
+	}
+	u.conn = conn
+	u.msg = randomPayload(u.payloadSize)
+	u.readBuf = make([]byte, 65536)
+	u.seq = 0
+	u.lost = 0
+	u.late = 0
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (u *udpRequester) Request() error {
+	payload := u.msg
+	if u.payload != nil {
+		payload = u.payload()
+	}
+	if u.sequence {
+		u.seq++
+		u.buf = append(u.buf[:0], make([]byte, 8)...)
+		binary.BigEndian.PutUint64(u.buf, u.seq)
+		payload = append(u.buf, payload...)
+		u.buf = payload
+	}
+	if _, err := u.conn.Write(payload); err != nil {
+		return err
+	}
+
+	if err := u.conn.SetReadDeadline(time.Now().Add(u.timeout)); err != nil {
+		return err
+	}
+	for {
+		n, err := u.conn.Read(u.readBuf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				u.lost++
+				return errDatagramLost
+			}
+			return err
+		}
+		if !u.sequence || (n >= 8 && binary.BigEndian.Uint64(u.readBuf) == u.seq) {
+			return nil
+		}
+		u.late++
+	}
+}
+
+// Report returns the number of lost datagrams and late replies.
+func (u *udpRequester) Report() *bench.Report {
+	return &bench.Report{Counters: map[string]uint64{
+		"udp.lost": u.lost,
+		"udp.late": u.late,
+	}}
+}
+
+// Teardown is called upon benchmark completion.
+func (u *udpRequester) Teardown() error {
+	if err := u.conn.Close(); err != nil {
+		return err
+	}
+	u.conn = nil
+	return nil
+}
@@ -0,0 +1,25 @@
+package requester_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestUDPRequester(t *testing.T) {
+	address := requestertest.UDPEcho(t)
+	for _, sequence := range []bool{false, true} {
+		factory := &requester.UDPRequesterFactory{
+			Address:     address,
+			PayloadSize: 64,
+			Sequence:    sequence,
+		}
+		requestertest.Check(t, factory, 10)
+		summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+		if got := summary.Counters["udp.lost"]; got != 0 {
+			t.Errorf("Sequence %v: got %d lost datagrams, want 0", sequence, got)
+		}
+	}
+}
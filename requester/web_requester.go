@@ -1,37 +1,538 @@
 package requester
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/ssd532/bench/v2"
+	"golang.org/x/net/http2"
 )
 
+// HTTPProtocol selects the HTTP protocol version used by the web requester.
+type HTTPProtocol int
+
+const (
+	// HTTP1 uses HTTP/1.1.
+	HTTP1 HTTPProtocol = iota
+
+	// HTTP2 forces HTTP/2 over TLS. Requests fail if the server doesn't
+	// negotiate h2.
+	HTTP2
+
+	// H2C uses HTTP/2 over cleartext TCP with prior knowledge, i.e. without
+	// an HTTP/1.1 upgrade.
+	H2C
+)
+
+// String returns the name of the protocol.
+func (p HTTPProtocol) String() string {
+	switch p {
+	case HTTP1:
+		return "HTTP/1.1"
+	case HTTP2:
+		return "HTTP/2"
+	case H2C:
+		return "h2c"
+	default:
+		return "HTTPProtocol(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
 // WebRequesterFactory implements RequesterFactory by creating a Requester
-// which makes GET requests to the provided URL.
+// which makes HTTP requests to the provided URL. By default each Benchmark
+// connection uses its own transport, so the number of connections maps to the
+// number of TCP connections to the server when keep-alives are enabled.
 type WebRequesterFactory struct {
 	URL string
+
+	// Protocol selects the HTTP protocol version. Defaults to HTTP1.
+	Protocol HTTPProtocol
+
+	// StreamsPerConnection is the number of Benchmark connections sharing a
+	// transport. With HTTP2 or H2C, their requests are multiplexed as
+	// concurrent streams on a single connection to the server. Defaults to 1.
+	StreamsPerConnection uint64
+
+	// Method is the HTTP method to use. Defaults to GET.
+	Method string
+
+	// Header contains the headers to send with every request. A Host header
+	// overrides the request host.
+	Header http.Header
+
+	// Body is sent as the request body. It's ignored if BodyGenerator is set.
+	Body []byte
+
+	// BodyGenerator, if set, is called for every request to produce the
+	// request body.
+	BodyGenerator PayloadGenerator
+
+	// ExpectedStatusCodes contains the response status codes considered
+	// successful. If empty, any 2xx status code is successful.
+	ExpectedStatusCodes []int
+
+	// Timeout limits the time taken by a single request, including reading
+	// the response body. Zero means no timeout.
+	Timeout time.Duration
+
+	// DisableKeepAlives causes a new connection to be established for every
+	// request.
+	DisableKeepAlives bool
+
+	// MaxIdleConns and MaxConnsPerHost configure the connection pool of each
+	// connection's transport. Zero means the http.Transport default. They
+	// don't apply to H2C, whose connections aren't pooled by http.Transport.
+	MaxIdleConns    int
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is the maximum time an idle connection is kept alive.
+	// Zero means the http.Transport default.
+	IdleConnTimeout time.Duration
+
+	// DisableCompression prevents the transport from requesting gzip
+	// compressed responses.
+	DisableCompression bool
+
+	// TLSConfig is used for HTTPS requests. It's cloned for every connection.
+	TLSConfig *tls.Config
+
+	// InsecureSkipVerify disables verification of the server's certificate.
+	InsecureSkipVerify bool
+
+	// RootCAs, if set, contains PEM encoded certificates used to verify the
+	// server's certificate instead of the system pool.
+	RootCAs []byte
+
+	// Trace enables per-phase timing of requests. DNS lookup, TCP connect,
+	// TLS handshake and time to first byte are reported as the "dns",
+	// "connect", "tls" and "ttfb" histograms in the Summary. With HTTP2 or
+	// H2C, requests sent while another request was in flight on the same
+	// connection are always counted as "conn.multiplexed".
+	Trace bool
+
+	mu         sync.Mutex
+	transports map[uint64]*webTransport
 }
 
 // GetRequester returns a new Requester, called for each Benchmark connection.
-func (w *WebRequesterFactory) GetRequester(uint64) bench.Requester {
-	return &webRequester{w.URL}
+func (w *WebRequesterFactory) GetRequester(num uint64) bench.Requester {
+	method := w.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	generator := w.BodyGenerator
+	if generator == nil && w.Body != nil {
+		generator = StaticPayload(w.Body)
+	}
+	return &webRequester{
+		url:                 w.URL,
+		method:              method,
+		header:              w.Header,
+		body:                generator,
+		expectedStatusCodes: w.ExpectedStatusCodes,
+		timeout:             w.Timeout,
+		disableKeepAlives:   w.DisableKeepAlives,
+		maxIdleConns:        w.MaxIdleConns,
+		maxConnsPerHost:     w.MaxConnsPerHost,
+		idleConnTimeout:     w.IdleConnTimeout,
+		disableCompression:  w.DisableCompression,
+		tlsConfig:           w.TLSConfig,
+		insecureSkipVerify:  w.InsecureSkipVerify,
+		rootCAs:             w.RootCAs,
+		trace:               w.Trace,
+		protocol:            w.Protocol,
+		factory:             w,
+		group:               num / streamsPerConnection(w.StreamsPerConnection),
+	}
+}
+
+// streamsPerConnection returns the configured number of streams per
+// connection, defaulting to 1.
+func streamsPerConnection(streams uint64) uint64 {
+	if streams == 0 {
+		return 1
+	}
+	return streams
+}
+
+// acquireTransport returns the transport shared by the requester's group of
+// Benchmark connections, creating it if necessary.
+func (w *WebRequesterFactory) acquireTransport(r *webRequester) (*webTransport, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.transports == nil {
+		w.transports = make(map[uint64]*webTransport)
+	}
+	transport, ok := w.transports[r.group]
+	if !ok {
+		roundTripper, err := r.newRoundTripper()
+		if err != nil {
+			return nil, err
+		}
+		transport = &webTransport{
+			roundTripper: roundTripper,
+			streams:      make(map[net.Conn]int),
+		}
+		w.transports[r.group] = transport
+	}
+	transport.refs++
+	return transport, nil
 }
 
-// webRequester implements Requester by making a GET request to the provided
-// URL.
+// releaseTransport releases the given group's transport, closing its idle
+// connections when no Benchmark connections are using it anymore.
+func (w *WebRequesterFactory) releaseTransport(group uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	transport, ok := w.transports[group]
+	if !ok {
+		return
+	}
+	transport.refs--
+	if transport.refs > 0 {
+		return
+	}
+	delete(w.transports, group)
+	if closer, ok := transport.roundTripper.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// webTransport is a transport shared by one or more Benchmark connections. It
+// tracks the number of in-flight requests on each connection to the server in
+// order to detect multiplexing.
+type webTransport struct {
+	roundTripper http.RoundTripper
+	refs         int
+	mu           sync.Mutex
+	streams      map[net.Conn]int
+}
+
+// openStream records a request starting on the given connection and
+// indicates if other requests were in flight on it.
+func (t *webTransport) openStream(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streams[conn]++
+	return t.streams[conn] > 1
+}
+
+// closeStream records a request completing on the given connection.
+func (t *webTransport) closeStream(conn net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.streams[conn]--; t.streams[conn] <= 0 {
+		delete(t.streams, conn)
+	}
+}
+
+// webRequester implements Requester by making an HTTP request to the provided
+// URL and checking the response status code.
 type webRequester struct {
-	url string
+	url                 string
+	method              string
+	header              http.Header
+	body                PayloadGenerator
+	expectedStatusCodes []int
+	timeout             time.Duration
+	disableKeepAlives   bool
+	maxIdleConns        int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	disableCompression  bool
+	tlsConfig           *tls.Config
+	insecureSkipVerify  bool
+	rootCAs             []byte
+	trace               bool
+	protocol            HTTPProtocol
+	factory             *WebRequesterFactory
+	group               uint64
+	transport           *webTransport
+	client              *http.Client
+	statusCodes         map[int]uint64
+	protos              map[string]uint64
+	reusedConns         uint64
+	multiplexed         uint64
+	dnsHistogram        *hdrhistogram.Histogram
+	connectHistogram    *hdrhistogram.Histogram
+	tlsHistogram        *hdrhistogram.Histogram
+	ttfbHistogram       *hdrhistogram.Histogram
 }
 
 // Setup prepares the Requester for benchmarking.
-func (w *webRequester) Setup() error { return nil }
+func (w *webRequester) Setup() error {
+	transport, err := w.factory.acquireTransport(w)
+	if err != nil {
+		return err
+	}
+	w.transport = transport
+	w.client = &http.Client{Transport: transport.roundTripper, Timeout: w.timeout}
+	w.statusCodes = make(map[int]uint64)
+	w.protos = make(map[string]uint64)
+	w.reusedConns = 0
+	w.multiplexed = 0
+	if w.trace {
+		w.dnsHistogram = bench.NewHistogram()
+		w.connectHistogram = bench.NewHistogram()
+		w.tlsHistogram = bench.NewHistogram()
+		w.ttfbHistogram = bench.NewHistogram()
+	}
+	return nil
+}
+
+// newRoundTripper returns a transport for the configured protocol.
+func (w *webRequester) newRoundTripper() (http.RoundTripper, error) {
+	tlsConfig, err := w.newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   w.disableKeepAlives,
+		DisableCompression:  w.disableCompression,
+		MaxIdleConns:        w.maxIdleConns,
+		MaxIdleConnsPerHost: w.maxIdleConns,
+		MaxConnsPerHost:     w.maxConnsPerHost,
+		IdleConnTimeout:     w.idleConnTimeout,
+	}
+	if w.maxIdleConns == 0 {
+		transport.MaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+	if w.idleConnTimeout == 0 {
+		transport.IdleConnTimeout = 90 * time.Second
+	}
+
+	// HTTP/2 connections are configured through the HTTP/1.1 transport, so
+	// they share its settings.
+	switch w.protocol {
+	case HTTP2:
+		if _, err := http2.ConfigureTransports(transport); err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.NextProtos = []string{http2.NextProtoTLS}
+	case H2C:
+		h2c, err := http2.ConfigureTransports(transport)
+		if err != nil {
+			return nil, err
+		}
+		// The configured transport only uses connections upgraded by
+		// the HTTP/1.1 transport, so let it dial its own.
+		h2c.ConnPool = nil
+		h2c.AllowHTTP = true
+		h2c.DialTLS = func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, 30*time.Second)
+		}
+		return h2c, nil
+	}
+	return transport, nil
+}
+
+// newTLSConfig returns the TLS configuration to use for the connection.
+func (w *webRequester) newTLSConfig() (*tls.Config, error) {
+	var config *tls.Config
+	if w.tlsConfig != nil {
+		config = w.tlsConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if w.insecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if len(w.rootCAs) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(w.rootCAs) {
+			return nil, fmt.Errorf("requester: no valid certificates in RootCAs")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
 
 // Request performs a synchronous request to the system under test.
 func (w *webRequester) Request() error {
-	resp, err := http.Get(w.url)
+	var body io.Reader
+	if w.body != nil {
+		body = bytes.NewReader(w.body())
+	}
+	req, err := http.NewRequest(w.method, w.url, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range w.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if host := w.header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	var phases *webPhases
+	if w.trace || w.protocol != HTTP1 {
+		phases = &webPhases{start: time.Now(), transport: w.transport}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), phases.clientTrace()))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if phases != nil {
+			w.recordPhases(phases)
+		}
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
 	resp.Body.Close()
-	return err
+	if phases != nil {
+		w.recordPhases(phases)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.protos[resp.Proto]++
+	w.statusCodes[resp.StatusCode]++
+	if w.protocol == HTTP2 && resp.ProtoMajor != 2 {
+		return fmt.Errorf("requester: server responded with %s instead of HTTP/2", resp.Proto)
+	}
+	if !w.isExpectedStatus(resp.StatusCode) {
+		return fmt.Errorf("requester: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isExpectedStatus indicates if the status code is considered successful.
+func (w *webRequester) isExpectedStatus(code int) bool {
+	if len(w.expectedStatusCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, expected := range w.expectedStatusCodes {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPhases records the phase timings of a completed request and releases
+// its stream on the connection.
+func (w *webRequester) recordPhases(p *webPhases) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		w.transport.closeStream(p.conn)
+	}
+	if p.reused {
+		w.reusedConns++
+	}
+	if p.multiplexed {
+		w.multiplexed++
+	}
+	if !w.trace {
+		return
+	}
+	record := func(h *hdrhistogram.Histogram, start, end time.Time) {
+		if !start.IsZero() && !end.IsZero() {
+			h.RecordValue(end.Sub(start).Nanoseconds())
+		}
+	}
+	record(w.dnsHistogram, p.dnsStart, p.dnsDone)
+	record(w.connectHistogram, p.connectStart, p.connectDone)
+	record(w.tlsHistogram, p.tlsStart, p.tlsDone)
+	record(w.ttfbHistogram, p.start, p.firstByte)
+}
+
+// Report returns the status code and protocol counts and, if tracing is
+// enabled, the per-phase latency histograms.
+func (w *webRequester) Report() *bench.Report {
+	report := &bench.Report{Counters: map[string]uint64{}}
+	for code, count := range w.statusCodes {
+		report.Counters["status."+strconv.Itoa(code)] = count
+	}
+	for proto, count := range w.protos {
+		report.Counters["proto."+proto] = count
+	}
+	if w.protocol != HTTP1 {
+		report.Counters["conn.multiplexed"] = w.multiplexed
+	}
+	if w.trace {
+		report.Counters["conn.reused"] = w.reusedConns
+		report.Histograms = map[string]*hdrhistogram.Histogram{
+			"dns":     w.dnsHistogram,
+			"connect": w.connectHistogram,
+			"tls":     w.tlsHistogram,
+			"ttfb":    w.ttfbHistogram,
+		}
+	}
+	return report
 }
 
 // Teardown is called upon benchmark completion.
-func (w *webRequester) Teardown() error { return nil }
+func (w *webRequester) Teardown() error {
+	w.factory.releaseTransport(w.group)
+	w.transport = nil
+	w.client = nil
+	return nil
+}
+
+// webPhases captures the timestamps of the phases of a single HTTP request and
+// the connection it was sent on. Dials may complete on a transport goroutine,
+// so access is synchronized.
+type webPhases struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+	multiplexed  bool
+	conn         net.Conn
+	transport    *webTransport
+}
+
+// clientTrace returns an httptrace.ClientTrace which populates the phases.
+func (p *webPhases) clientTrace() *httptrace.ClientTrace {
+	set := func(t *time.Time) {
+		p.mu.Lock()
+		*t = time.Now()
+		p.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { set(&p.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { set(&p.dnsDone) },
+		ConnectStart:      func(string, string) { set(&p.connectStart) },
+		ConnectDone:       func(string, string, error) { set(&p.connectDone) },
+		TLSHandshakeStart: func() { set(&p.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { set(&p.tlsDone) },
+		GotFirstResponseByte: func() {
+			set(&p.firstByte)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mu.Lock()
+			p.reused = info.Reused
+			p.conn = info.Conn
+			p.multiplexed = p.transport.openStream(info.Conn)
+			p.mu.Unlock()
+		},
+	}
+}
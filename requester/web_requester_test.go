@@ -0,0 +1,84 @@
+package requester_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestWebRequester(t *testing.T) {
+	server := requestertest.HTTP(t)
+	factory := &requester.WebRequesterFactory{
+		URL:    server.URL,
+		Method: http.MethodPost,
+		Body:   []byte("hello"),
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	if got := summary.Counters["proto.HTTP/1.1"]; got != summary.SuccessTotal {
+		t.Errorf("got %d HTTP/1.1 responses, want %d", got, summary.SuccessTotal)
+	}
+}
+
+func TestWebRequesterHTTP2(t *testing.T) {
+	server := requestertest.HTTP2(t)
+	factory := &requester.WebRequesterFactory{
+		URL:                  server.URL,
+		Protocol:             requester.HTTP2,
+		StreamsPerConnection: 2,
+		RootCAs:              pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		Trace:                true,
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	if got := summary.Counters["proto.HTTP/2.0"]; got != summary.SuccessTotal {
+		t.Errorf("got %d HTTP/2.0 responses, want %d", got, summary.SuccessTotal)
+	}
+}
+
+func TestWebRequesterHTTP2NotNegotiated(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	factory := &requester.WebRequesterFactory{
+		URL:                server.URL,
+		Protocol:           requester.HTTP2,
+		InsecureSkipVerify: true,
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	if err := r.Request(); err == nil {
+		t.Error("Request succeeded over HTTP/1.1")
+	}
+}
+
+func TestWebRequesterH2C(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), &http2.Server{}))
+	defer server.Close()
+	factory := &requester.WebRequesterFactory{
+		URL:               server.URL,
+		Protocol:          requester.H2C,
+		DisableKeepAlives: true,
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 1, 200*time.Millisecond)
+	if got := summary.Counters["proto.HTTP/2.0"]; got != summary.SuccessTotal {
+		t.Errorf("got %d HTTP/2.0 responses, want %d", got, summary.SuccessTotal)
+	}
+	if got := summary.Counters["conn.reused"]; got != 0 {
+		t.Errorf("reused %d connections with keep-alives disabled", got)
+	}
+}
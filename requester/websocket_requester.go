@@ -0,0 +1,227 @@
+package requester
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssd532/bench/v2"
+)
+
+// WebSocketCorrelator matches WebSocket replies to requests using a
+// correlation ID carried in the frames.
+type WebSocketCorrelator interface {
+	// Message returns the frame to send for the request with the given ID
+	// and payload.
+	Message(id string, payload []byte) []byte
+
+	// ID returns the correlation ID of a received frame and indicates if the
+	// frame has one.
+	ID(frame []byte) (string, bool)
+}
+
+// PrefixCorrelator is a WebSocketCorrelator which prefixes the payload with
+// the correlation ID followed by Separator, e.g. "42:payload".
+type PrefixCorrelator struct {
+	Separator byte
+}
+
+// Message returns the frame to send for the request with the given ID and
+// payload.
+func (p PrefixCorrelator) Message(id string, payload []byte) []byte {
+	frame := make([]byte, 0, len(id)+1+len(payload))
+	frame = append(frame, id...)
+	frame = append(frame, p.Separator)
+	return append(frame, payload...)
+}
+
+// ID returns the correlation ID of a received frame and indicates if the
+// frame has one.
+func (p PrefixCorrelator) ID(frame []byte) (string, bool) {
+	i := bytes.IndexByte(frame, p.Separator)
+	if i < 0 {
+		return "", false
+	}
+	return string(frame[:i]), true
+}
+
+// WebSocketRequesterFactory implements RequesterFactory by creating a
+// Requester which sends a frame over a WebSocket connection and waits for the
+// reply. Each Benchmark connection dials its own WebSocket connection, which
+// is closed and dialed again for the next request after a failed request, e.g.
+// a reply timing out.
+type WebSocketRequesterFactory struct {
+	URL string
+
+	// Header contains the headers to send with the opening handshake.
+	Header http.Header
+
+	// Subprotocols contains the subprotocols to request.
+	Subprotocols []string
+
+	// PayloadSize is the size of the random payload sent with every request.
+	// It's ignored if Payload is set.
+	PayloadSize int
+
+	// Payload, if set, is called for every request to produce the payload.
+	Payload PayloadGenerator
+
+	// Binary sends binary frames rather than text frames.
+	Binary bool
+
+	// Correlator, if set, is used to match replies to requests. Otherwise
+	// the next frame received is the reply. Frames not matching the request
+	// are counted as "ws.unmatched" and skipped.
+	Correlator WebSocketCorrelator
+
+	// Timeout limits the time spent waiting for a reply. Defaults to 30
+	// seconds.
+	Timeout time.Duration
+
+	// TLSConfig is used for wss connections.
+	TLSConfig *tls.Config
+}
+
+// GetRequester returns a new Requester, called for each Benchmark connection.
+func (w *WebSocketRequesterFactory) GetRequester(num uint64) bench.Requester {
+	messageType := websocket.TextMessage
+	if w.Binary {
+		messageType = websocket.BinaryMessage
+	}
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &webSocketRequester{
+		url:          w.URL,
+		header:       w.Header,
+		subprotocols: w.Subprotocols,
+		payloadSize:  w.PayloadSize,
+		payload:      w.Payload,
+		messageType:  messageType,
+		correlator:   w.Correlator,
+		timeout:      timeout,
+		tlsConfig:    w.TLSConfig,
+		idPrefix:     strconv.FormatUint(num, 10) + "-",
+	}
+}
+
+// webSocketRequester implements Requester by sending a frame over a WebSocket
+// connection and waiting for the reply.
+type webSocketRequester struct {
+	url          string
+	header       http.Header
+	subprotocols []string
+	payloadSize  int
+	payload      PayloadGenerator
+	messageType  int
+	correlator   WebSocketCorrelator
+	timeout      time.Duration
+	tlsConfig    *tls.Config
+	idPrefix     string
+	conn         *websocket.Conn
+	msg          []byte
+	seq          uint64
+	unmatched    uint64
+}
+
+// Setup prepares the Requester for benchmarking.
+func (w *webSocketRequester) Setup() error {
+	if err := w.dial(); err != nil {
+		return err
+	}
+	w.msg = randomPayload(w.payloadSize)
+	w.seq = 0
+	w.unmatched = 0
+	return nil
+}
+
+// dial opens the WebSocket connection.
+func (w *webSocketRequester) dial() error {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 30 * time.Second,
+		Subprotocols:     w.subprotocols,
+		TLSClientConfig:  w.tlsConfig,
+	}
+	conn, _, err := dialer.Dial(w.url, w.header)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// Request performs a synchronous request to the system under test.
+func (w *webSocketRequester) Request() error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return err
+		}
+	}
+	if err := w.roundTrip(); err != nil {
+		// The connection can't be read from after an error, and a late
+		// reply would be taken for the reply to the next request.
+		w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+// roundTrip sends a frame and waits for the reply.
+func (w *webSocketRequester) roundTrip() error {
+	payload := w.msg
+	if w.payload != nil {
+		payload = w.payload()
+	}
+	var id string
+	if w.correlator != nil {
+		w.seq++
+		id = w.idPrefix + strconv.FormatUint(w.seq, 10)
+		payload = w.correlator.Message(id, payload)
+	}
+	if err := w.conn.WriteMessage(w.messageType, payload); err != nil {
+		return err
+	}
+
+	if err := w.conn.SetReadDeadline(time.Now().Add(w.timeout)); err != nil {
+		return err
+	}
+	for {
+		_, frame, err := w.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if w.correlator == nil {
+			return nil
+		}
+		if replyID, ok := w.correlator.ID(frame); ok && replyID == id {
+			return nil
+		}
+		w.unmatched++
+	}
+}
+
+// Report returns the number of frames which didn't match a request.
+func (w *webSocketRequester) Report() *bench.Report {
+	return &bench.Report{Counters: map[string]uint64{"ws.unmatched": w.unmatched}}
+}
+
+// Teardown is called upon benchmark completion.
+func (w *webSocketRequester) Teardown() error {
+	if w.conn == nil {
+		return nil
+	}
+	w.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	if err := w.conn.Close(); err != nil {
+		return err
+	}
+	w.conn = nil
+	return nil
+}
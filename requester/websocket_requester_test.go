@@ -0,0 +1,84 @@
+package requester_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ssd532/bench/v2/requester"
+	"github.com/ssd532/bench/v2/requester/requestertest"
+)
+
+func TestWebSocketRequester(t *testing.T) {
+	factory := &requester.WebSocketRequesterFactory{
+		URL:         requestertest.WebSocket(t),
+		PayloadSize: 100,
+	}
+	requestertest.Check(t, factory, 10)
+	requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+}
+
+func TestWebSocketRequesterCorrelator(t *testing.T) {
+	factory := &requester.WebSocketRequesterFactory{
+		URL:         requestertest.WebSocket(t),
+		PayloadSize: 100,
+		Binary:      true,
+		Correlator:  requester.PrefixCorrelator{Separator: ':'},
+	}
+	requestertest.Check(t, factory, 10)
+	summary := requestertest.Benchmark(t, factory, 2, 200*time.Millisecond)
+	if got := summary.Counters["ws.unmatched"]; got != 0 {
+		t.Errorf("got %d unmatched frames, want 0", got)
+	}
+}
+
+func TestWebSocketRequesterRedials(t *testing.T) {
+	// The server echoes the first two messages of every connection and
+	// never replies to the third.
+	var conns int32
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		atomic.AddInt32(&conns, 1)
+		for i := 0; ; i++ {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if i == 2 {
+				continue
+			}
+			if err := conn.WriteMessage(messageType, message); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	factory := &requester.WebSocketRequesterFactory{
+		URL:         "ws" + server.URL[len("http"):],
+		PayloadSize: 10,
+		Timeout:     100 * time.Millisecond,
+	}
+	r := factory.GetRequester(0)
+	if err := r.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Teardown()
+	for i := 0; i < 6; i++ {
+		err := r.Request()
+		if timedOut := i%3 == 2; timedOut != (err != nil) {
+			t.Errorf("Request %d: got error %v", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(&conns); got != 2 {
+		t.Errorf("got %d connections, want 2", got)
+	}
+}
@@ -1,6 +1,7 @@
 package bench
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -21,6 +22,74 @@ type Summary struct {
 	ErrorHistogram              *hdrhistogram.Histogram
 	UncorrectedErrorHistogram   *hdrhistogram.Histogram
 	Throughput                  float64
+
+	// Histograms and Counters contain additional results contributed by
+	// Requesters implementing Reporter, merged across connections by name.
+	// Metadata contains the settings they reported.
+	Histograms map[string]*hdrhistogram.Histogram
+	Counters   map[string]uint64
+	Metadata   map[string]string
+
+	// Labels contains the results per request label for Requesters
+	// implementing Labeler.
+	Labels map[string]*LabelSummary
+}
+
+// LabelSummary contains the results of the requests sharing a label.
+type LabelSummary struct {
+	SuccessTotal                uint64
+	ErrorTotal                  uint64
+	SuccessHistogram            *hdrhistogram.Histogram
+	UncorrectedSuccessHistogram *hdrhistogram.Histogram
+	ErrorHistogram              *hdrhistogram.Histogram
+	UncorrectedErrorHistogram   *hdrhistogram.Histogram
+}
+
+// newLabelSummary returns an empty LabelSummary.
+func newLabelSummary() *LabelSummary {
+	return &LabelSummary{
+		SuccessHistogram:            NewHistogram(),
+		UncorrectedSuccessHistogram: NewHistogram(),
+		ErrorHistogram:              NewHistogram(),
+		UncorrectedErrorHistogram:   NewHistogram(),
+	}
+}
+
+// copy returns a copy of the LabelSummary.
+func (l *LabelSummary) copy() *LabelSummary {
+	return &LabelSummary{
+		SuccessTotal:                l.SuccessTotal,
+		ErrorTotal:                  l.ErrorTotal,
+		SuccessHistogram:            hdrhistogram.Import(l.SuccessHistogram.Export()),
+		UncorrectedSuccessHistogram: hdrhistogram.Import(l.UncorrectedSuccessHistogram.Export()),
+		ErrorHistogram:              hdrhistogram.Import(l.ErrorHistogram.Export()),
+		UncorrectedErrorHistogram:   hdrhistogram.Import(l.UncorrectedErrorHistogram.Export()),
+	}
+}
+
+// merge the other LabelSummary into this one.
+func (l *LabelSummary) merge(o *LabelSummary) {
+	l.SuccessTotal += o.SuccessTotal
+	l.ErrorTotal += o.ErrorTotal
+	l.SuccessHistogram.Merge(o.SuccessHistogram)
+	l.UncorrectedSuccessHistogram.Merge(o.UncorrectedSuccessHistogram)
+	l.ErrorHistogram.Merge(o.ErrorHistogram)
+	l.UncorrectedErrorHistogram.Merge(o.UncorrectedErrorHistogram)
+}
+
+// Report contains additional results contributed by a Requester which
+// implements Reporter.
+type Report struct {
+	// Histograms contains named latency distributions in nanoseconds.
+	Histograms map[string]*hdrhistogram.Histogram
+
+	// Counters contains named event counts.
+	Counters map[string]uint64
+
+	// Metadata describes the configuration used for the run, e.g. client
+	// settings. If connections report different values for a key, the first
+	// one is kept.
+	Metadata map[string]string
 }
 
 // String returns a stringified version of the Summary.
@@ -54,6 +123,36 @@ func (s *Summary) GenerateErrorLatencyDistribution(percentiles histwriter.Percen
 	return generateLatencyDistribution(s.ErrorHistogram, s.UncorrectedErrorHistogram, s.RequestRate, percentiles, file)
 }
 
+// GenerateLabelLatencyDistribution generates a text file containing the
+// latency distribution of the requests with the given label in a format
+// plottable by http://hdrhistogram.github.io/HdrHistogram/plotFiles.html.
+// Percentiles is a list of percentiles to include, e.g. 10.0, 50.0, 99.0,
+// 99.99, etc. If percentiles is nil, it defaults to a logarithmic percentile
+// scale. If a request rate was specified for the benchmark, this will also
+// generate an uncorrected distribution file which does not account for
+// coordinated omission.
+func (s *Summary) GenerateLabelLatencyDistribution(label string, percentiles histwriter.Percentiles, file string) error {
+	labelSummary, ok := s.Labels[label]
+	if !ok {
+		return fmt.Errorf("bench: no requests labeled %q", label)
+	}
+	return generateLatencyDistribution(labelSummary.SuccessHistogram, labelSummary.UncorrectedSuccessHistogram, s.RequestRate, percentiles, file)
+}
+
+// GenerateReportLatencyDistribution generates a text file containing the
+// latency distribution of the named Histogram contributed by a Reporter in a
+// format plottable by http://hdrhistogram.github.io/HdrHistogram/plotFiles.html.
+// Percentiles is a list of percentiles to include, e.g. 10.0, 50.0, 99.0,
+// 99.99, etc. If percentiles is nil, it defaults to a logarithmic percentile
+// scale.
+func (s *Summary) GenerateReportLatencyDistribution(name string, percentiles histwriter.Percentiles, file string) error {
+	histogram, ok := s.Histograms[name]
+	if !ok {
+		return fmt.Errorf("bench: no histogram named %q", name)
+	}
+	return histwriter.WriteDistributionFile(histogram, percentiles, 0.000001, file)
+}
+
 func getOneByPercentile(percentile float64) float64 {
 	if percentile < 100 {
 		return 1 / (1 - (percentile / 100))
@@ -90,4 +189,190 @@ func (s *Summary) merge(o *Summary) {
 	s.ErrorTotal += o.ErrorTotal
 	s.Throughput += o.Throughput
 	s.RequestRate += o.RequestRate
+	s.addReport(&Report{Histograms: o.Histograms, Counters: o.Counters, Metadata: o.Metadata})
+	if s.Labels == nil {
+		s.Labels = make(map[string]*LabelSummary)
+	}
+	for label, labelSummary := range o.Labels {
+		if existing, ok := s.Labels[label]; ok {
+			existing.merge(labelSummary)
+		} else {
+			s.Labels[label] = labelSummary.copy()
+		}
+	}
+}
+
+// addReport merges the Report into this Summary.
+func (s *Summary) addReport(r *Report) {
+	if r == nil {
+		return
+	}
+	if s.Histograms == nil {
+		s.Histograms = make(map[string]*hdrhistogram.Histogram)
+	}
+	if s.Counters == nil {
+		s.Counters = make(map[string]uint64)
+	}
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]string)
+	}
+	for name, histogram := range r.Histograms {
+		if existing, ok := s.Histograms[name]; ok {
+			existing.Merge(histogram)
+		} else {
+			s.Histograms[name] = hdrhistogram.Import(histogram.Export())
+		}
+	}
+	for name, count := range r.Counters {
+		s.Counters[name] += count
+	}
+	for key, value := range r.Metadata {
+		if _, ok := s.Metadata[key]; !ok {
+			s.Metadata[key] = value
+		}
+	}
+}
+
+// summaryJSON is the JSON representation of a Summary. Histograms are encoded
+// in the compressed HdrHistogram V2 format.
+type summaryJSON struct {
+	Connections                 uint64
+	RequestRate                 uint64
+	SuccessTotal                uint64
+	ErrorTotal                  uint64
+	TimeElapsed                 time.Duration
+	SuccessHistogram            encodedHistogram
+	UncorrectedSuccessHistogram encodedHistogram
+	ErrorHistogram              encodedHistogram
+	UncorrectedErrorHistogram   encodedHistogram
+	Throughput                  float64
+	Histograms                  map[string]encodedHistogram `json:",omitempty"`
+	Counters                    map[string]uint64           `json:",omitempty"`
+	Metadata                    map[string]string           `json:",omitempty"`
+	Labels                      map[string]labelSummaryJSON `json:",omitempty"`
+}
+
+// labelSummaryJSON is the JSON representation of a LabelSummary.
+type labelSummaryJSON struct {
+	SuccessTotal                uint64
+	ErrorTotal                  uint64
+	SuccessHistogram            encodedHistogram
+	UncorrectedSuccessHistogram encodedHistogram
+	ErrorHistogram              encodedHistogram
+	UncorrectedErrorHistogram   encodedHistogram
+}
+
+// MarshalJSON encodes the Summary as JSON, so it can be sent to another
+// process and decoded with UnmarshalJSON.
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	j := summaryJSON{
+		Connections:                 s.Connections,
+		RequestRate:                 s.RequestRate,
+		SuccessTotal:                s.SuccessTotal,
+		ErrorTotal:                  s.ErrorTotal,
+		TimeElapsed:                 s.TimeElapsed,
+		SuccessHistogram:            encodedHistogram{s.SuccessHistogram},
+		UncorrectedSuccessHistogram: encodedHistogram{s.UncorrectedSuccessHistogram},
+		ErrorHistogram:              encodedHistogram{s.ErrorHistogram},
+		UncorrectedErrorHistogram:   encodedHistogram{s.UncorrectedErrorHistogram},
+		Throughput:                  s.Throughput,
+		Histograms:                  make(map[string]encodedHistogram, len(s.Histograms)),
+		Counters:                    s.Counters,
+		Metadata:                    s.Metadata,
+		Labels:                      make(map[string]labelSummaryJSON, len(s.Labels)),
+	}
+	for name, histogram := range s.Histograms {
+		j.Histograms[name] = encodedHistogram{histogram}
+	}
+	for label, l := range s.Labels {
+		j.Labels[label] = labelSummaryJSON{
+			SuccessTotal:                l.SuccessTotal,
+			ErrorTotal:                  l.ErrorTotal,
+			SuccessHistogram:            encodedHistogram{l.SuccessHistogram},
+			UncorrectedSuccessHistogram: encodedHistogram{l.UncorrectedSuccessHistogram},
+			ErrorHistogram:              encodedHistogram{l.ErrorHistogram},
+			UncorrectedErrorHistogram:   encodedHistogram{l.UncorrectedErrorHistogram},
+		}
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a Summary encoded by MarshalJSON.
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	var j summaryJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*s = Summary{
+		Connections:                 j.Connections,
+		RequestRate:                 j.RequestRate,
+		SuccessTotal:                j.SuccessTotal,
+		ErrorTotal:                  j.ErrorTotal,
+		TimeElapsed:                 j.TimeElapsed,
+		SuccessHistogram:            j.SuccessHistogram.Histogram,
+		UncorrectedSuccessHistogram: j.UncorrectedSuccessHistogram.Histogram,
+		ErrorHistogram:              j.ErrorHistogram.Histogram,
+		UncorrectedErrorHistogram:   j.UncorrectedErrorHistogram.Histogram,
+		Throughput:                  j.Throughput,
+		Histograms:                  make(map[string]*hdrhistogram.Histogram, len(j.Histograms)),
+		Counters:                    j.Counters,
+		Metadata:                    j.Metadata,
+		Labels:                      make(map[string]*LabelSummary, len(j.Labels)),
+	}
+	if s.Counters == nil {
+		s.Counters = make(map[string]uint64)
+	}
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]string)
+	}
+	for name, histogram := range j.Histograms {
+		s.Histograms[name] = histogram.Histogram
+	}
+	for label, l := range j.Labels {
+		s.Labels[label] = &LabelSummary{
+			SuccessTotal:                l.SuccessTotal,
+			ErrorTotal:                  l.ErrorTotal,
+			SuccessHistogram:            l.SuccessHistogram.Histogram,
+			UncorrectedSuccessHistogram: l.UncorrectedSuccessHistogram.Histogram,
+			ErrorHistogram:              l.ErrorHistogram.Histogram,
+			UncorrectedErrorHistogram:   l.UncorrectedErrorHistogram.Histogram,
+		}
+	}
+	return nil
+}
+
+// encodedHistogram is a Histogram which is encoded as a JSON string in the
+// compressed HdrHistogram V2 format.
+type encodedHistogram struct {
+	*hdrhistogram.Histogram
+}
+
+// MarshalJSON encodes the Histogram.
+func (h encodedHistogram) MarshalJSON() ([]byte, error) {
+	if h.Histogram == nil {
+		return []byte("null"), nil
+	}
+	encoded, err := h.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(encoded))
+}
+
+// UnmarshalJSON decodes the Histogram.
+func (h *encodedHistogram) UnmarshalJSON(data []byte) error {
+	var encoded *string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if encoded == nil {
+		h.Histogram = nil
+		return nil
+	}
+	histogram, err := hdrhistogram.Decode([]byte(*encoded))
+	if err != nil {
+		return err
+	}
+	h.Histogram = histogram
+	return nil
 }